@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// cassetteEntry is one recorded request/response pair, stored as readable
+// JSON so recordings double as decoder fixtures.
+type cassetteEntry struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Cookies     []string    `json:"cookies,omitempty"` // names only; values aren't recorded
+	Body        string      `json:"body"`
+}
+
+// credentialHeaders are response headers that can carry live session
+// credentials, which don't belong in recordings meant to be committed.
+var credentialHeaders = []string{"Set-Cookie", "Set-Cookie2", "Authorization", "Proxy-Authorization"}
+
+// replayedCookie stands in for a cookie value that wasn't recorded.
+const replayedCookie = "replayed"
+
+// Cassette records every HTTP exchange to a directory, or serves a previous
+// recording back without touching the network. Exchanges are keyed by
+// method, URL and request body; repeats of the same request are numbered so
+// a replay sees responses in the order they were recorded.
+type Cassette struct {
+	dir    string
+	replay bool
+	next   http.RoundTripper
+
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+// NewRecorder returns a cassette that forwards requests to next and saves
+// each exchange under dir.
+func NewRecorder(dir string, next http.RoundTripper) (*Cassette, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating cassette directory: %v", err)
+	}
+	return &Cassette{dir: dir, next: next, seen: make(map[string]int)}, nil
+}
+
+// NewReplayer returns a cassette that answers only from recordings in dir.
+func NewReplayer(dir string) (*Cassette, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cassette: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("cassette %s is not a directory", dir)
+	}
+	return &Cassette{dir: dir, replay: true, seen: make(map[string]int)}, nil
+}
+
+func cassetteKey(method, url, body string) string {
+	sum := sha256.Sum256([]byte(method + " " + url + "\n" + body))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func (c *Cassette) filename(method, url, key string, occurrence int) string {
+	name := "root"
+	if i := strings.IndexByte(url, '?'); i >= 0 {
+		url = url[:i]
+	}
+	if base := path.Base(url); base != "" && base != "/" && base != "." {
+		name = base
+	}
+	return filepath.Join(c.dir, fmt.Sprintf("%s-%s-%s-%d.json", method, name, key, occurrence))
+}
+
+// occurrence returns how many times this request was seen before.
+func (c *Cassette) occurrence(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.seen[key]
+	c.seen[key]++
+	return n
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	url := req.URL.String()
+	key := cassetteKey(req.Method, url, string(body))
+	n := c.occurrence(key)
+
+	if c.replay {
+		return c.play(req, key, n)
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	entry := cassetteEntry{
+		Method:      req.Method,
+		URL:         url,
+		RequestBody: string(body),
+		Status:      resp.StatusCode,
+		Header:      resp.Header.Clone(),
+		Body:        string(respBody),
+	}
+	for _, cookie := range resp.Cookies() {
+		entry.Cookies = append(entry.Cookies, cookie.Name)
+	}
+	for _, name := range credentialHeaders {
+		entry.Header.Del(name)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(c.filename(req.Method, url, key, n), data, 0644); err != nil {
+		return nil, fmt.Errorf("error recording %s %s: %v", req.Method, url, err)
+	}
+	return resp, nil
+}
+
+// play serves the nth recording of key, falling back to the latest one when
+// the request is repeated more often than it was during recording.
+func (c *Cassette) play(req *http.Request, key string, n int) (*http.Response, error) {
+	url := req.URL.String()
+	var data []byte
+	var err error
+	for ; n >= 0; n-- {
+		data, err = os.ReadFile(c.filename(req.Method, url, key, n))
+		if err == nil || !os.IsNotExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, url, c.dir)
+		}
+		return nil, err
+	}
+
+	var entry cassetteEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("error decoding recording of %s %s: %v", req.Method, url, err)
+	}
+	if entry.Header == nil {
+		entry.Header = make(http.Header)
+	}
+	// Set the cookies the server set, so a session still gets one
+	for _, name := range entry.Cookies {
+		entry.Header.Add("Set-Cookie", (&http.Cookie{Name: name, Value: replayedCookie, Path: "/"}).String())
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+		StatusCode:    entry.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Header,
+		Body:          io.NopCloser(strings.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}, nil
+}
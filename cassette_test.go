@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayReproducesRecording(t *testing.T) {
+	fake := newFakeUVic(t)
+	cassette := filepath.Join(t.TempDir(), "cassette")
+
+	recorded := runMain(t, fake, scratchDir(t), "-record="+cassette, "-course", "CSC", "110")
+	if !strings.Contains(recorded, "Section B03 (CRN: 20013)") {
+		t.Fatalf("recording run failed:\n%s", recorded)
+	}
+	files, err := os.ReadDir(cassette)
+	if err != nil || len(files) == 0 {
+		t.Fatalf("nothing recorded in %s: %v", cassette, err)
+	}
+
+	// Live session cookies stay out of the recording
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(cassette, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(strings.ToLower(string(data)), "set-cookie") {
+			t.Errorf("%s records a cookie:\n%s", file.Name(), data)
+		}
+	}
+
+	// The replay must not reach the network at all
+	fake.Close()
+	replayed := runMain(t, fake, scratchDir(t), "-replay="+cassette, "-course", "CSC", "110")
+	if replayed != recorded {
+		t.Errorf("replay differs from recording\nrecorded:\n%s\nreplayed:\n%s", recorded, replayed)
+	}
+
+	// but still hands out a session cookie where the server set one
+	replayer, err := NewReplayer(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", fake.bannerURL()+"/term/termSelection?mode=search", nil)
+	resp, err := replayer.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if cookies := resp.Cookies(); len(cookies) != 1 || cookies[0].Name != "JSESSIONID" || cookies[0].Value != replayedCookie {
+		t.Errorf("replayed cookies = %v", cookies)
+	}
+}
+
+func TestReplayReportsMissingRecording(t *testing.T) {
+	fake := newFakeUVic(t)
+	cassette := t.TempDir()
+
+	out := runMain(t, fake, scratchDir(t), "-replay="+cassette, "-course", "CSC", "110")
+	if !strings.Contains(out, "no recorded response for GET") {
+		t.Errorf("expected a missing recording error, got:\n%s", out)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// kualiPageSize is how many courses each catalog listing request asks for.
+const kualiPageSize = 500
+
+// fetchCatalogCourses pages through every course in a Kuali catalog,
+// returning each as Kuali sent it so no field is lost. Kuali may cap the
+// page size, so paging only stops at an empty page. Courses repeated
+// across pages are dropped, which also ends the loop against a server that
+// ignores the paging parameters.
+func fetchCatalogCourses(ctx context.Context, catalogID string) ([]json.RawMessage, error) {
+	var courses []json.RawMessage
+	seen := make(map[string]bool)
+	for skip := 0; ; {
+		url := fmt.Sprintf("%s/catalog/courses/%s?skip=%d&limit=%d", kualiBaseURL, catalogID, skip, kualiPageSize)
+		body, err := kualiGet(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		var page []json.RawMessage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse Kuali course list: %v", err)
+		}
+
+		added := 0
+		for _, raw := range page {
+			var course Course
+			if err := json.Unmarshal(raw, &course); err != nil {
+				return nil, fmt.Errorf("failed to parse Kuali course: %v", err)
+			}
+			if course.PID == "" || seen[course.PID] {
+				continue
+			}
+			seen[course.PID] = true
+			courses = append(courses, raw)
+			added++
+		}
+		if added == 0 {
+			return courses, nil
+		}
+		skip += len(page)
+	}
+}
+
+// CatalogChanges summarizes how a freshly synced catalog differs from the
+// previous courses.json. A course Kuali renumbered keeps its pid, so it is
+// reported as renamed rather than removed and added.
+type CatalogChanges struct {
+	Added   []Course
+	Removed []Course
+	Renamed [][2]Course // old, new
+}
+
+func diffCatalogs(old, new []Course) CatalogChanges {
+	before := make(map[string]Course)
+	for _, c := range old {
+		before[c.CourseID] = c
+	}
+	after := make(map[string]Course)
+	for _, c := range new {
+		after[c.CourseID] = c
+	}
+
+	// Courses whose number went away, by pid, to match against new numbers
+	gone := make(map[string]Course)
+	for _, c := range old {
+		if _, ok := after[c.CourseID]; !ok && c.PID != "" {
+			gone[c.PID] = c
+		}
+	}
+
+	var changes CatalogChanges
+	for _, c := range new {
+		prev, ok := before[c.CourseID]
+		if !ok {
+			if prev, ok = gone[c.PID]; ok && c.PID != "" {
+				delete(gone, c.PID)
+				changes.Renamed = append(changes.Renamed, [2]Course{prev, c})
+			} else {
+				changes.Added = append(changes.Added, c)
+			}
+		} else if prev.Title != c.Title {
+			changes.Renamed = append(changes.Renamed, [2]Course{prev, c})
+		}
+	}
+	for _, c := range old {
+		if _, ok := after[c.CourseID]; ok {
+			continue
+		}
+		if _, ok := gone[c.PID]; ok || c.PID == "" {
+			changes.Removed = append(changes.Removed, c)
+		}
+	}
+	return changes
+}
+
+func (c CatalogChanges) print(w io.Writer) {
+	for _, course := range c.Added {
+		fmt.Fprintf(w, "+ %s %s: %s\n", course.SubjectCode.Name, catalogNumber(course), course.Title)
+	}
+	for _, course := range c.Removed {
+		fmt.Fprintf(w, "- %s %s: %s\n", course.SubjectCode.Name, catalogNumber(course), course.Title)
+	}
+	for _, pair := range c.Renamed {
+		name := pair[1].SubjectCode.Name + " " + catalogNumber(pair[1])
+		if pair[0].CourseID != pair[1].CourseID {
+			name = pair[0].SubjectCode.Name + " " + catalogNumber(pair[0]) + " -> " + name
+		}
+		title := pair[1].Title
+		if pair[0].Title != pair[1].Title {
+			title = pair[0].Title + " -> " + title
+		}
+		fmt.Fprintf(w, "~ %s: %s\n", name, title)
+	}
+	fmt.Fprintf(w, "%d added, %d removed, %d renamed\n", len(c.Added), len(c.Removed), len(c.Renamed))
+}
+
+// syncCatalog regenerates filename from the current Kuali catalog and
+// reports what changed. The file is replaced atomically, so an interrupted
+// sync leaves the old catalog in place.
+func syncCatalog(ctx context.Context, filename string) (CatalogChanges, error) {
+	catalogID, err := currentKualiCatalog(ctx)
+	if err != nil {
+		return CatalogChanges{}, err
+	}
+	raw, err := fetchCatalogCourses(ctx, catalogID)
+	if err != nil {
+		return CatalogChanges{}, err
+	}
+	if len(raw) == 0 {
+		return CatalogChanges{}, fmt.Errorf("Kuali catalog %s lists no courses", catalogID)
+	}
+
+	courses := make([]Course, len(raw))
+	for i := range raw {
+		if err := json.Unmarshal(raw[i], &courses[i]); err != nil {
+			return CatalogChanges{}, err
+		}
+	}
+	order := make([]int, len(raw))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return courses[order[a]].CourseID < courses[order[b]].CourseID })
+	sorted := make([]json.RawMessage, len(raw))
+	sortedCourses := make([]Course, len(raw))
+	for i, j := range order {
+		sorted[i], sortedCourses[i] = raw[j], courses[j]
+	}
+
+	var old []Course
+	if _, err := os.Stat(filename); err == nil {
+		if old, err = loadCoursesFromJSON(filename); err != nil {
+			// An unreadable old catalog is replaced; everything counts as added
+			fmt.Fprintf(progress, "Warning: %v\n", err)
+		}
+	}
+
+	data, err := json.Marshal(sorted)
+	if err != nil {
+		return CatalogChanges{}, err
+	}
+	if err := writeFileAtomic(filename, data); err != nil {
+		return CatalogChanges{}, err
+	}
+
+	return diffCatalogs(old, sortedCourses), nil
+}
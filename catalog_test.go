@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCatalogSync(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	out := runMain(t, fake, dir, "-catalog-sync")
+
+	for _, want := range []string{
+		"+ CSC 115: Fundamentals of Programming III",
+		"- MATH 100: Calculus I",
+		"~ CSC 111: Fundamentals of Programming with Engineering Applications -> Fundamentals of Programming II",
+		"1 added, 1 removed, 1 renamed",
+		"Wrote catalog " + fakeCatalogID + " to courses.json",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	courses, err := loadCoursesFromJSON(filepath.Join(dir, "courses.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range courses {
+		ids = append(ids, c.CourseID+"/"+c.PID)
+	}
+	if got := strings.Join(ids, " "); got != "CSC110/rkgWq1OpQE CSC111/rJGckOp7E CSC115/HkxW9JOTmE" {
+		t.Errorf("synced courses = %s", got)
+	}
+
+	// Kuali fields the scraper does not model are kept
+	data, err := os.ReadFile(filepath.Join(dir, "courses.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"catalogActivationDate":"2025-11-15"`) {
+		t.Errorf("catalog lost Kuali fields:\n%s", data)
+	}
+
+	// A course added mid-year can now be looked up
+	out = runMain(t, fake, dir, "-course", "CSC", "115")
+	if strings.Contains(out, "not found in courses.json") {
+		t.Errorf("new course still unknown:\n%s", out)
+	}
+}
+
+func TestDiffCatalogsMatchesRenumberedCourses(t *testing.T) {
+	course := func(pid, subject, number, title string) Course {
+		return Course{PID: pid, CourseID: subject + number, Title: title, SubjectCode: SubjectCode{Name: subject}}
+	}
+	old := []Course{
+		course("p1", "CSC", "110", "Fundamentals of Programming I"),
+		course("p2", "CSC", "116", "Programming Essentials"),
+		course("p3", "MATH", "100", "Calculus I"),
+		course("p4", "SENG", "265", "Software Development Methods"),
+	}
+	new := []Course{
+		course("p1", "CSC", "110", "Fundamentals of Programming I"),
+		course("p2", "CSC", "117", "Programming Essentials"),
+		course("p4", "SENG", "275", "Software Testing"),
+		course("p5", "MATH", "101", "Calculus II"),
+	}
+
+	var out strings.Builder
+	diffCatalogs(old, new).print(&out)
+	for _, want := range []string{
+		"+ MATH 101: Calculus II",
+		"- MATH 100: Calculus I",
+		"~ CSC 116 -> CSC 117: Programming Essentials\n",
+		"~ SENG 265 -> SENG 275: Software Development Methods -> Software Testing",
+		"1 added, 1 removed, 2 renamed",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 )
 
 // Core structs remain the same
 type Course struct {
+	PID         string      `json:"pid"`
 	CourseID    string      `json:"__catalogCourseId"`
 	Title       string      `json:"title"`
 	SubjectCode SubjectCode `json:"subjectCode"`
@@ -181,11 +184,42 @@ type Credits struct {
 	Chosen string `json:"chosen"`
 }
 
-func fetchKualiCourseInfo(pid string) (*KualiCourseInfo, error) {
-	url := fmt.Sprintf("https://uvic.kuali.co/api/v1/catalog/course/65eb47906641d7001c157bc4/%s", pid)
+// kualiBaseURL is the root of the Kuali catalog API; -kuali-url overrides it.
+var kualiBaseURL = "https://uvic.kuali.co/api/v1"
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+// kualiCatalogID is the catalog courses are looked up in. "current" asks
+// Kuali which catalog is published, once per run.
+var kualiCatalogID = "current"
+
+var kualiCatalogMu sync.Mutex
+
+func currentKualiCatalog(ctx context.Context) (string, error) {
+	kualiCatalogMu.Lock()
+	defer kualiCatalogMu.Unlock()
+
+	if kualiCatalogID != "current" {
+		return kualiCatalogID, nil
+	}
+	body, err := kualiGet(ctx, kualiBaseURL+"/catalog/public/catalogs/current")
+	if err != nil {
+		return "", fmt.Errorf("failed to find the current Kuali catalog: %v", err)
+	}
+	var catalog struct {
+		ID string `json:"_id"`
+	}
+	if err := json.Unmarshal(body, &catalog); err != nil {
+		return "", fmt.Errorf("failed to parse Kuali catalog: %v", err)
+	}
+	if catalog.ID == "" {
+		return "", fmt.Errorf("Kuali did not report a current catalog")
+	}
+	kualiCatalogID = catalog.ID
+	return kualiCatalogID, nil
+}
+
+func kualiGet(ctx context.Context, url string) ([]byte, error) {
+	client := &http.Client{Transport: sharedTransport}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
@@ -208,6 +242,18 @@ func fetchKualiCourseInfo(pid string) (*KualiCourseInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %v", err)
 	}
+	return body, nil
+}
+
+func fetchKualiCourseInfo(ctx context.Context, pid string) (*KualiCourseInfo, error) {
+	catalogID, err := currentKualiCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := kualiGet(ctx, fmt.Sprintf("%s/catalog/course/%s/%s", kualiBaseURL, catalogID, pid))
+	if err != nil {
+		return nil, err
+	}
 
 	// Try to unmarshal as single object first
 	var info KualiCourseInfo
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Crawl modes for -all
+const (
+	crawlPerCourse  = "course"
+	crawlPerSubject = "subject"
+	crawlWholeTerm  = "term"
+)
+
+func catalogNumber(c Course) string {
+	return strings.TrimPrefix(c.CourseID, c.SubjectCode.Name)
+}
+
+func unavailableRow(term string, c Course) CSVExportRow {
+	return CSVExportRow{
+		Term:         term,
+		Subject:      c.SubjectCode.Name,
+		CourseName:   c.Title,
+		CourseNumber: catalogNumber(c),
+		Available:    false,
+	}
+}
+
+// sectionDetails returns the meeting times and instructors of a section,
+// using the copy embedded in search results when Banner included one.
+func sectionDetails(ctx context.Context, session *Session, term string, section CourseSection) ([]MeetingFaculty, error) {
+	if len(section.MeetingsFaculty) == 0 {
+		details, err := session.fetchSessions(ctx, term, section.CourseReferenceNumber)
+		if err != nil {
+			return nil, err
+		}
+		return details.Fmt, nil
+	}
+
+	meetings := make([]MeetingFaculty, len(section.MeetingsFaculty))
+	copy(meetings, section.MeetingsFaculty)
+	for i := range meetings {
+		// Search results attach instructors to the section, not the meeting
+		if len(meetings[i].Faculty) == 0 {
+			meetings[i].Faculty = section.Faculty
+		}
+		if meetings[i].Section == "" {
+			meetings[i].Section = section.Section
+		}
+	}
+	return meetings, nil
+}
+
+func formatCredits(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// sectionRows maps a Banner section and its meeting details to export rows,
+// one per meeting. A section without any meetings still gets a row so it
+// isn't silently dropped.
+func sectionRows(term, title string, section CourseSection, details []MeetingFaculty) []CSVExportRow {
+	if section.Term != "" {
+		term = section.Term
+	}
+	if title == "" {
+		title = section.CourseTitle
+	}
+
+	units := section.CreditHours
+	if units == 0 {
+		units = section.CreditHourLow
+	}
+
+	base := CSVExportRow{
+		Term:                term,
+		Subject:             section.Subject,
+		CourseName:          title,
+		CourseNumber:        section.CourseNumber,
+		CRN:                 section.CourseReferenceNumber,
+		Section:             section.Section,
+		Instructor:          getInstructors(section.Faculty),
+		InstructorEmail:     getInstructorEmails(section.Faculty),
+		Faculty:             section.Faculty,
+		InstructionalMethod: section.InstructionalMethodDescription,
+		Units:               formatCredits(units),
+		CreditHours:         formatCredits(units),
+		Available:           true,
+		CampusDescription:   section.CampusDescription,
+		Enrollment:          section.Enrollment,
+		MaximumEnrollment:   section.MaximumEnrollment,
+		SeatsAvailable:      section.SeatsAvailable,
+		WaitCount:           section.WaitCount,
+		WaitCapacity:        section.WaitCapacity,
+		LinkGroup:           linkKey(section),
+	}
+	if len(details) == 0 {
+		return []CSVExportRow{base}
+	}
+
+	var rows []CSVExportRow
+	for _, meeting := range details {
+		mt := meeting.MeetingTime
+		row := base
+
+		if mt.BeginTime != "" {
+			row.Time = fmt.Sprintf("%s-%s", formatTime(mt.BeginTime), formatTime(mt.EndTime))
+		}
+		row.Days = getDays(mt)
+		if mt.Building != "" && mt.Room != "" {
+			row.Location = fmt.Sprintf("%s %s", mt.Building, mt.Room)
+		}
+		if mt.StartDate != "" && mt.EndDate != "" {
+			row.DateRange = fmt.Sprintf("%s - %s", mt.StartDate, mt.EndDate)
+		}
+		row.StartDate = mt.StartDate
+		row.EndDate = mt.EndDate
+		row.ScheduleType = mt.MeetingScheduleType
+		row.Campus = mt.Campus
+		if mt.CampusDescription != "" {
+			row.CampusDescription = mt.CampusDescription
+		}
+		row.BuildingCode = mt.Building
+		row.BuildingName = html.UnescapeString(mt.BuildingDescription)
+		row.RoomNumber = mt.Room
+		row.MeetingType = mt.MeetingType
+		row.MeetingDescription = mt.MeetingTypeDescription
+		if mt.CreditHourSession != 0 {
+			row.CreditHours = formatCredits(mt.CreditHourSession)
+		}
+
+		if len(meeting.Faculty) > 0 {
+			row.Instructor = getInstructors(meeting.Faculty)
+			row.InstructorEmail = getInstructorEmails(meeting.Faculty)
+			row.Faculty = meeting.Faculty
+		}
+		if row.InstructionalMethod == "" {
+			row.InstructionalMethod = meeting.InstructionalMethodDescription
+		}
+		if row.Units == "" {
+			row.Units = formatCredits(meeting.CreditHourHigh)
+		}
+
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// fetchCourseRows fetches every section of one course as export rows.
+func fetchCourseRows(ctx context.Context, session *Session, term, subject, number string) ([]CSVExportRow, error) {
+	response, err := session.fetchCourseInfo(ctx, term, subject, number)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []CSVExportRow
+	for _, section := range response.Data {
+		if section.CourseReferenceNumber == "" {
+			continue
+		}
+		details, err := sectionDetails(ctx, session, term, section)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching session for CRN %s: %v", section.CourseReferenceNumber, err)
+		}
+		rows = append(rows, sectionRows(term, "", section, details)...)
+	}
+	return rows, nil
+}
+
+// interruptContexts returns two contexts tied to Ctrl-C. The first interrupt
+// cancels stop, telling the crawl not to start new work while in-flight
+// requests finish; a second interrupt cancels abort to give up on those too.
+func interruptContexts(parent context.Context) (stop, abort context.Context, release func()) {
+	stop, cancelStop := context.WithCancel(parent)
+	abort, cancelAbort := context.WithCancel(parent)
+
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
+		select {
+		case <-sigCh:
+			fmt.Fprintln(progress, "\nInterrupted: finishing in-flight requests (press Ctrl-C again to abort)...")
+			cancelStop()
+		case <-abort.Done():
+			return
+		}
+		select {
+		case <-sigCh:
+			fmt.Fprintln(progress, "\nAborting in-flight requests...")
+			cancelAbort()
+		case <-abort.Done():
+		}
+	}()
+
+	return stop, abort, func() {
+		signal.Stop(sigCh)
+		cancelStop()
+		cancelAbort()
+	}
+}
+
+// incompleteName marks an export written from an interrupted crawl, e.g.
+// courses.csv becomes courses.incomplete.csv.
+func incompleteName(filename string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + ".incomplete" + ext
+}
+
+type crawler struct {
+	session *Session
+	term    string
+	results chan<- CSVExportRow
+	errorCh chan<- error
+
+	// stop is cancelled once no new courses or sections should be started.
+	stop context.Context
+
+	// journal checkpoints progress so an interrupted crawl can be resumed.
+	journal *crawlJournal
+
+	// limit caps the sections fetched, for dry runs; 0 means no limit.
+	limit int
+	taken atomic.Int64
+}
+
+// dryRunLimit is how many courses, and how many sections, a dry run fetches.
+var dryRunLimit = 10
+
+// fail reports err and, when journaling, records key as work to retry.
+func (cr *crawler) fail(kind, key string, err error) {
+	cr.errorCh <- err
+	if cr.journal == nil {
+		return
+	}
+	if jerr := cr.journal.failed(kind, key, err); jerr != nil {
+		cr.errorCh <- jerr
+	}
+}
+
+func (cr *crawler) checkpoint(kind, key string, rows []CSVExportRow) {
+	if cr.journal == nil {
+		return
+	}
+	if err := cr.journal.done(kind, key, rows); err != nil {
+		cr.errorCh <- err
+	}
+}
+
+// restored returns rows a previous run already recorded for key.
+func (cr *crawler) restored(kind, key string) ([]CSVExportRow, bool) {
+	if cr.journal == nil {
+		return nil, false
+	}
+	return cr.journal.completed(kind, key)
+}
+
+func (cr *crawler) emit(rows []CSVExportRow) {
+	for _, row := range rows {
+		cr.results <- row
+	}
+}
+
+// take claims one of the limited sections, reporting false once none are left.
+func (cr *crawler) take() bool {
+	return cr.limit <= 0 || cr.taken.Add(1) <= int64(cr.limit)
+}
+
+func (cr *crawler) stopped() bool {
+	return cr.stop.Err() != nil
+}
+
+// acquire waits for a worker slot, giving up if the crawl is stopped first.
+func (cr *crawler) acquire(sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		if cr.stopped() {
+			<-sem
+			return false
+		}
+		return true
+	case <-cr.stop.Done():
+		return false
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// crawlByCourse runs one Banner search per catalog course.
+func (cr *crawler) crawlByCourse(ctx context.Context, courses []Course) {
+	sem := make(chan struct{}, 10)
+	var wg sync.WaitGroup
+
+	for _, c := range courses {
+		subject := c.SubjectCode.Name
+		number := catalogNumber(c)
+
+		wg.Add(1)
+		go func(c Course, subject, number string) {
+			defer wg.Done()
+
+			courseKey := subject + " " + number
+			if rows, ok := cr.restored(journalCourse, courseKey); ok {
+				if len(rows) == 0 {
+					rows = []CSVExportRow{unavailableRow(cr.term, c)}
+				}
+				cr.emit(rows)
+				return
+			}
+
+			if !cr.acquire(sem) {
+				return
+			}
+			defer func() { <-sem }()
+
+			fmt.Fprintf(progress, "Fetching details for %s %s (%s)...\n", subject, number, c.Title)
+
+			// The scheduler already retried throttled and failed requests
+			response, err := cr.session.fetchCourseInfo(ctx, cr.term, subject, number)
+			if err != nil {
+				cr.fail(journalCourse, courseKey, fmt.Errorf("error fetching course info for %s %s: %v", subject, number, err))
+				// Even if there's an error, we'll record the course as
+				// unavailable, unless the crawl was aborted under it
+				if ctx.Err() == nil {
+					cr.results <- unavailableRow(cr.term, c)
+				}
+				return
+			}
+
+			if len(response.Data) == 0 {
+				rows := []CSVExportRow{unavailableRow(cr.term, c)}
+				cr.emit(rows)
+				cr.checkpoint(journalCourse, courseKey, rows)
+				return
+			}
+
+			var courseRows []CSVExportRow
+			complete := true
+			for _, section := range response.Data {
+				crn := section.CourseReferenceNumber
+				if crn == "" {
+					continue
+				}
+				if !cr.take() {
+					complete = false
+					break
+				}
+
+				rows, ok := cr.restored(journalCRN, crn)
+				if !ok {
+					details, err := cr.session.fetchSessions(ctx, cr.term, crn)
+					if err != nil {
+						cr.fail(journalCRN, crn, fmt.Errorf("error fetching session for CRN %s: %v", crn, err))
+						complete = false
+						continue
+					}
+					rows = sectionRows(cr.term, c.Title, section, details.Fmt)
+					cr.checkpoint(journalCRN, crn, rows)
+				}
+
+				cr.emit(rows)
+				courseRows = append(courseRows, rows...)
+			}
+			if complete {
+				cr.checkpoint(journalCourse, courseKey, courseRows)
+			}
+		}(c, subject, number)
+	}
+
+	wg.Wait()
+}
+
+// crawlBySubject runs one paginated Banner search per subject, or a single
+// search covering the whole term, and joins the sections back to the
+// catalog. Catalog courses with no sections are recorded as unavailable;
+// sections of courses missing from the catalog keep their Banner title.
+func (cr *crawler) crawlBySubject(ctx context.Context, courses []Course, wholeTerm bool) {
+	catalog := make(map[string]Course)
+	seen := make(map[string]bool)
+	var subjects []string
+	for _, c := range courses {
+		subject := c.SubjectCode.Name
+		if !seen[subject] {
+			seen[subject] = true
+			subjects = append(subjects, subject)
+		}
+		catalog[subject+" "+catalogNumber(c)] = c
+	}
+	sort.Strings(subjects)
+	if wholeTerm {
+		subjects = []string{""}
+	}
+
+	var mu sync.Mutex
+	offered := make(map[string]bool)
+	failed := make(map[string]bool)
+	completed := make(map[string]bool)
+
+	sem := make(chan struct{}, 10)
+	var wg sync.WaitGroup
+
+	for _, subject := range subjects {
+		wg.Add(1)
+		go func(subject string) {
+			defer wg.Done()
+
+			markOffered := func(rows []CSVExportRow) {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, row := range rows {
+					offered[row.Subject+" "+row.CourseNumber] = true
+				}
+			}
+
+			if rows, ok := cr.restored(journalSubject, subject); ok {
+				markOffered(rows)
+				cr.emit(rows)
+				mu.Lock()
+				completed[subject] = true
+				mu.Unlock()
+				return
+			}
+
+			if !cr.acquire(sem) {
+				return
+			}
+			defer func() { <-sem }()
+
+			if subject == "" {
+				fmt.Fprintf(progress, "Fetching every section offered in %s...\n", cr.term)
+			} else {
+				fmt.Fprintf(progress, "Fetching sections for subject %s...\n", subject)
+			}
+
+			var subjectRows []CSVExportRow
+			complete := true
+			for section, err := range cr.session.searchSections(ctx, cr.term, subject, "", nil) {
+				if err != nil {
+					cr.fail(journalSubject, subject, fmt.Errorf("error searching subject %q: %v", subject, err))
+					mu.Lock()
+					failed[subject] = true
+					mu.Unlock()
+					return
+				}
+				if cr.stopped() {
+					return
+				}
+				crn := section.CourseReferenceNumber
+				if crn == "" {
+					continue
+				}
+				if !cr.take() {
+					// Out of sections, so stop paging; courses of this subject
+					// that weren't reached aren't known to be unavailable
+					return
+				}
+
+				key := section.Subject + " " + section.CourseNumber
+				title := section.CourseTitle
+				if c, ok := catalog[key]; ok {
+					title = c.Title
+				}
+				mu.Lock()
+				offered[key] = true
+				mu.Unlock()
+
+				rows, ok := cr.restored(journalCRN, crn)
+				if !ok {
+					details, err := sectionDetails(ctx, cr.session, cr.term, section)
+					if err != nil {
+						cr.fail(journalCRN, crn, fmt.Errorf("error fetching session for CRN %s: %v", crn, err))
+						complete = false
+						continue
+					}
+					rows = sectionRows(cr.term, title, section, details)
+					cr.checkpoint(journalCRN, crn, rows)
+				}
+				cr.emit(rows)
+				subjectRows = append(subjectRows, rows...)
+			}
+
+			if complete {
+				cr.checkpoint(journalSubject, subject, subjectRows)
+			}
+			mu.Lock()
+			completed[subject] = true
+			mu.Unlock()
+		}(subject)
+	}
+
+	wg.Wait()
+
+	for _, c := range courses {
+		subject := c.SubjectCode.Name
+		if offered[subject+" "+catalogNumber(c)] {
+			continue
+		}
+		if failed[subject] || failed[""] {
+			cr.errorCh <- fmt.Errorf("error fetching course info for %s %s: subject search failed", subject, catalogNumber(c))
+		} else if !completed[subject] && !completed[""] {
+			// Interrupted before this subject was searched; we know nothing
+			continue
+		}
+		cr.results <- unavailableRow(cr.term, c)
+	}
+}
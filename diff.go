@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// readExportFile reads rows back from a csv, json or ndjson export of this
+// tool, going by the file extension or else the first character.
+func readExportFile(path string) ([]CSVExportRow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	switch format {
+	case formatCSV, formatJSON, formatNDJSON:
+	case "jsonl":
+		format = formatNDJSON
+	default:
+		format = formatCSV
+		switch trimmed := strings.TrimSpace(string(data)); {
+		case strings.HasPrefix(trimmed, "["):
+			format = formatJSON
+		case strings.HasPrefix(trimmed, "{"):
+			format = formatNDJSON
+		}
+	}
+
+	var rows []CSVExportRow
+	switch format {
+	case formatCSV:
+		rows, err = readCSVExport(strings.NewReader(string(data)))
+	case formatJSON:
+		var outputs []CourseOutput
+		if err = json.Unmarshal(data, &outputs); err == nil {
+			for _, out := range outputs {
+				rows = append(rows, fromCourseOutput(out))
+			}
+		}
+	case formatNDJSON:
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var out CourseOutput
+			if err = json.Unmarshal(scanner.Bytes(), &out); err != nil {
+				break
+			}
+			rows = append(rows, fromCourseOutput(out))
+		}
+		if err == nil {
+			err = scanner.Err()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s as %s: %v", path, format, err)
+	}
+	return rows, nil
+}
+
+// readCSVExport maps the columns of csvHeader back onto rows.
+func readCSVExport(r io.Reader) ([]CSVExportRow, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{"Term", "CRN"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("no %s column", name)
+		}
+	}
+
+	var rows []CSVExportRow
+	for _, record := range records[1:] {
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		number := func(name string) int {
+			n, _ := strconv.Atoi(get(name))
+			return n
+		}
+		rows = append(rows, CSVExportRow{
+			Term:                get("Term"),
+			Subject:             get("Subject"),
+			CourseName:          get("Course Name"),
+			CourseNumber:        get("Course Number"),
+			CRN:                 get("CRN"),
+			Section:             get("Section"),
+			Time:                get("Time"),
+			Days:                get("Days"),
+			Location:            get("Location"),
+			DateRange:           get("Date Range"),
+			ScheduleType:        get("Schedule Type"),
+			Instructor:          get("Instructor"),
+			InstructionalMethod: get("Instructional Method"),
+			Units:               get("Units"),
+			Available:           get("Available") == "true",
+			Campus:              get("Campus"),
+			CampusDescription:   get("Campus Description"),
+			BuildingCode:        get("Building Code"),
+			BuildingName:        get("Building Name"),
+			RoomNumber:          get("Room Number"),
+			MeetingType:         get("Meeting Type"),
+			MeetingDescription:  get("Meeting Description"),
+			InstructorEmail:     get("Instructor Email"),
+			CreditHours:         get("Credit Hours"),
+			StartDate:           get("Start Date"),
+			EndDate:             get("End Date"),
+			Enrollment:          number("Enrollment"),
+			MaximumEnrollment:   number("Maximum Enrollment"),
+			SeatsAvailable:      number("Seats Available"),
+			WaitCount:           number("Wait Count"),
+			WaitCapacity:        number("Wait Capacity"),
+			LinkGroup:           get("Link Group"),
+		})
+	}
+	return rows, nil
+}
+
+// fromCourseOutput undoes toCourseOutput as far as it can.
+func fromCourseOutput(out CourseOutput) CSVExportRow {
+	row := CSVExportRow{
+		Term:                out.Term,
+		CRN:                 out.CRN,
+		Subject:             out.Subject,
+		CourseNumber:        out.CourseNumber,
+		Section:             out.Section,
+		CourseName:          out.Title,
+		Instructor:          out.Professor,
+		InstructorEmail:     out.Email,
+		Time:                out.Schedule,
+		ScheduleType:        out.ScheduleType,
+		Location:            out.Location,
+		BuildingName:        out.Building,
+		RoomNumber:          out.Room,
+		CampusDescription:   out.Campus,
+		Days:                out.Days,
+		CreditHours:         out.CreditHours,
+		InstructionalMethod: out.InstructionType,
+		DateRange:           out.DateRange,
+		StartDate:           out.StartDate,
+		EndDate:             out.EndDate,
+		Available:           out.Available,
+		SeatsAvailable:      out.SeatsAvailable,
+		WaitCount:           out.WaitCount,
+		WaitCapacity:        out.WaitCapacity,
+		LinkGroup:           out.LinkGroup,
+	}
+	if enrolled, maximum, ok := strings.Cut(out.Enrollment, "/"); ok {
+		row.Enrollment, _ = strconv.Atoi(enrolled)
+		row.MaximumEnrollment, _ = strconv.Atoi(maximum)
+	}
+	return row
+}
+
+// loadCrawl loads one side of a diff: an export file if arg names one, or
+// else a stored snapshot of the -semester term, which is only resolved
+// when first needed.
+func loadCrawl(store snapshotStore, arg string, semester *string, resolved *bool) ([]CSVExportRow, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return readExportFile(arg)
+	}
+	if !*resolved {
+		stored, err := store.terms()
+		if err != nil {
+			return nil, err
+		}
+		term, err := localTerm(*semester, stored)
+		if err != nil {
+			return nil, err
+		}
+		*semester, *resolved = term, true
+	}
+	info, err := store.find(*semester, arg)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither an export file nor a snapshot: %v", arg, err)
+	}
+	return store.load(info)
+}
+
+// meetingState is what diff compares of one meeting.
+type meetingState struct {
+	kind, start string
+	days, time  string
+	room        string
+}
+
+func (m meetingState) String() string {
+	return strings.Join(strings.Fields(fmt.Sprintf("%s %s %s", m.days, m.time, m.room)), " ")
+}
+
+// sectionState gathers a section's export rows, one per meeting.
+type sectionState struct {
+	row         CSVExportRow
+	meetings    []meetingState
+	instructors []string
+}
+
+func (s *sectionState) key() string {
+	return s.row.Term + " " + s.row.CRN
+}
+
+// sectionStates groups rows by term and CRN. Unavailable course rows have
+// no section and are skipped.
+func sectionStates(rows []CSVExportRow) map[string]*sectionState {
+	sections := make(map[string]*sectionState)
+	instructors := make(map[string]map[string]bool)
+	for _, row := range rows {
+		if row.CRN == "" {
+			continue
+		}
+		key := row.Term + " " + row.CRN
+		s, ok := sections[key]
+		if !ok {
+			s = &sectionState{row: row}
+			sections[key] = s
+			instructors[key] = make(map[string]bool)
+		}
+		if row.Days != "" || row.Time != "" || row.Location != "" {
+			s.meetings = append(s.meetings, meetingState{
+				kind: row.MeetingType, start: row.StartDate,
+				days: row.Days, time: row.Time, room: row.Location,
+			})
+		}
+
+		names := strings.Split(instructorNames(row), ", ")
+		if len(row.Faculty) > 0 {
+			names = nil
+			for _, f := range row.Faculty {
+				names = append(names, f.DisplayName)
+			}
+		}
+		for _, name := range names {
+			if name = strings.TrimSpace(name); name != "" {
+				instructors[key][name] = true
+			}
+		}
+	}
+
+	for key, s := range sections {
+		sort.SliceStable(s.meetings, func(i, j int) bool {
+			a, b := s.meetings[i], s.meetings[j]
+			if a.kind != b.kind {
+				return a.kind < b.kind
+			}
+			return a.start < b.start
+		})
+		for name := range instructors[key] {
+			s.instructors = append(s.instructors, name)
+		}
+		sort.Strings(s.instructors)
+	}
+	return sections
+}
+
+// FieldChange is one changed property of a section.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// SectionChange is a section that was added, cancelled or changed between
+// two crawls.
+type SectionChange struct {
+	Term    string        `json:"term"`
+	CRN     string        `json:"crn"`
+	Subject string        `json:"subject"`
+	Number  string        `json:"course_number"`
+	Section string        `json:"section"`
+	Title   string        `json:"title"`
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+func newSectionChange(row CSVExportRow) SectionChange {
+	return SectionChange{
+		Term:    row.Term,
+		CRN:     row.CRN,
+		Subject: row.Subject,
+		Number:  row.CourseNumber,
+		Section: row.Section,
+		Title:   row.CourseName,
+	}
+}
+
+func (c SectionChange) label() string {
+	return fmt.Sprintf("%s %s %s (CRN %s, %s)", c.Subject, c.Number, c.Section, c.CRN, c.Term)
+}
+
+// CrawlDiff lists what changed between two crawls, in course order.
+type CrawlDiff struct {
+	Added     []SectionChange `json:"added"`
+	Cancelled []SectionChange `json:"cancelled"`
+	Changed   []SectionChange `json:"changed"`
+}
+
+// compareSections lists how a section's meetings, instructors and
+// capacity changed. Meetings are paired by type and start date, so a
+// moved lecture shows as a day, time or room change; when meetings were
+// added or dropped the whole schedule is reported instead.
+func compareSections(old, cur *sectionState) []FieldChange {
+	var changes []FieldChange
+	add := func(field, before, after string) {
+		if before != after {
+			changes = append(changes, FieldChange{field, before, after})
+		}
+	}
+
+	if len(old.meetings) == len(cur.meetings) {
+		for i := range old.meetings {
+			add("days", old.meetings[i].days, cur.meetings[i].days)
+			add("time", old.meetings[i].time, cur.meetings[i].time)
+			add("room", old.meetings[i].room, cur.meetings[i].room)
+		}
+	} else {
+		schedule := func(meetings []meetingState) string {
+			var parts []string
+			for _, m := range meetings {
+				parts = append(parts, m.String())
+			}
+			return strings.Join(parts, "; ")
+		}
+		add("meetings", schedule(old.meetings), schedule(cur.meetings))
+	}
+
+	add("instructors", strings.Join(old.instructors, ", "), strings.Join(cur.instructors, ", "))
+	add("capacity", strconv.Itoa(old.row.MaximumEnrollment), strconv.Itoa(cur.row.MaximumEnrollment))
+	add("wait_capacity", strconv.Itoa(old.row.WaitCapacity), strconv.Itoa(cur.row.WaitCapacity))
+	return changes
+}
+
+// diffCrawls compares two crawls keyed by term and CRN.
+func diffCrawls(oldRows, newRows []CSVExportRow) CrawlDiff {
+	old, cur := sectionStates(oldRows), sectionStates(newRows)
+	d := CrawlDiff{Added: []SectionChange{}, Cancelled: []SectionChange{}, Changed: []SectionChange{}}
+	for key, s := range cur {
+		before, ok := old[key]
+		if !ok {
+			d.Added = append(d.Added, newSectionChange(s.row))
+			continue
+		}
+		if changes := compareSections(before, s); len(changes) > 0 {
+			change := newSectionChange(s.row)
+			change.Changes = changes
+			d.Changed = append(d.Changed, change)
+		}
+	}
+	for key, s := range old {
+		if _, ok := cur[key]; !ok {
+			d.Cancelled = append(d.Cancelled, newSectionChange(s.row))
+		}
+	}
+
+	for _, list := range [][]SectionChange{d.Added, d.Cancelled, d.Changed} {
+		sort.Slice(list, func(i, j int) bool {
+			a, b := list[i], list[j]
+			if a.Term != b.Term {
+				return a.Term < b.Term
+			}
+			if a.Subject+a.Number != b.Subject+b.Number {
+				return a.Subject+a.Number < b.Subject+b.Number
+			}
+			if a.Section != b.Section {
+				return a.Section < b.Section
+			}
+			return a.CRN < b.CRN
+		})
+	}
+	return d
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// writeDiff writes a diff as +/-/~ lines, JSON or Markdown tables.
+func writeDiff(w io.Writer, d CrawlDiff, format string) error {
+	var b strings.Builder
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+
+	case formatText:
+		for _, c := range d.Added {
+			fmt.Fprintf(&b, "+ %s: %s\n", c.label(), c.Title)
+		}
+		for _, c := range d.Cancelled {
+			fmt.Fprintf(&b, "- %s: %s\n", c.label(), c.Title)
+		}
+		for _, c := range d.Changed {
+			var parts []string
+			for _, f := range c.Changes {
+				parts = append(parts, fmt.Sprintf("%s %s -> %s", strings.ReplaceAll(f.Field, "_", " "), orNone(f.Old), orNone(f.New)))
+			}
+			fmt.Fprintf(&b, "~ %s: %s\n", c.label(), strings.Join(parts, "; "))
+		}
+		fmt.Fprintf(&b, "%d added, %d cancelled, %d changed\n", len(d.Added), len(d.Cancelled), len(d.Changed))
+
+	case formatMarkdown:
+		sections := []struct {
+			heading string
+			list    []SectionChange
+		}{{"Added", d.Added}, {"Cancelled", d.Cancelled}}
+		for _, s := range sections {
+			fmt.Fprintf(&b, "## %s (%d)\n\n", s.heading, len(s.list))
+			if len(s.list) == 0 {
+				continue
+			}
+			b.WriteString("| Term | CRN | Course | Section | Title |\n| --- | --- | --- | --- | --- |\n")
+			for _, c := range s.list {
+				fmt.Fprintf(&b, "| %s | %s | %s %s | %s | %s |\n", c.Term, c.CRN, c.Subject, c.Number, c.Section, markdownCell(c.Title))
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## Changed (%d)\n\n", len(d.Changed))
+		if len(d.Changed) > 0 {
+			b.WriteString("| Term | CRN | Course | Section | Change | Old | New |\n| --- | --- | --- | --- | --- | --- | --- |\n")
+			for _, c := range d.Changed {
+				for _, f := range c.Changes {
+					fmt.Fprintf(&b, "| %s | %s | %s %s | %s | %s | %s | %s |\n", c.Term, c.CRN, c.Subject, c.Number, c.Section,
+						strings.ReplaceAll(f.Field, "_", " "), markdownCell(orNone(f.Old)), markdownCell(orNone(f.New)))
+				}
+			}
+		}
+
+	default:
+		return fmt.Errorf("-diff writes text, json or markdown, not %s", format)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
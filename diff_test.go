@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffCrawls(t *testing.T) {
+	section := func(crn, days, time, room, instructor string, capacity int) CSVExportRow {
+		return CSVExportRow{
+			Term: "202509", Subject: "CSC", CourseNumber: "110", CourseName: "Fundamentals of Programming I",
+			CRN: crn, Section: "A" + crn[3:], Available: true, MeetingType: "CLAS", StartDate: "09/03/2025",
+			Days: days, Time: time, Location: room, Instructor: instructor, MaximumEnrollment: capacity,
+		}
+	}
+	old := []CSVExportRow{
+		section("10001", "MR", "10:00-11:20", "ECS 123", "Jane Doe", 100),
+		section("10002", "TWF", "13:30-14:20", "CLE A127", "Jane Doe", 80),
+		section("10003", "W", "", "", "", 30),
+		{Term: "202509", Subject: "CSC", CourseNumber: "999", CourseName: "Not offered"},
+	}
+	cur := []CSVExportRow{
+		section("10001", "MR", "10:00-11:20", "ECS 125", "John Smith", 120),
+		section("10003", "W", "", "", "", 30),
+		section("10004", "MR", "16:30-17:50", "ECS 123", "", 60),
+	}
+	lab := section("10003", "R", "14:30-17:20", "ECS 258", "", 30)
+	lab.MeetingType = "LAB"
+	cur = append(cur, lab)
+
+	d := diffCrawls(old, cur)
+	if len(d.Added) != 1 || d.Added[0].CRN != "10004" {
+		t.Errorf("added = %+v", d.Added)
+	}
+	if len(d.Cancelled) != 1 || d.Cancelled[0].CRN != "10002" {
+		t.Errorf("cancelled = %+v", d.Cancelled)
+	}
+	if len(d.Changed) != 2 {
+		t.Fatalf("changed = %+v", d.Changed)
+	}
+	want := []FieldChange{
+		{"room", "ECS 123", "ECS 125"},
+		{"instructors", "Jane Doe", "John Smith"},
+		{"capacity", "100", "120"},
+	}
+	if !reflect.DeepEqual(d.Changed[0].Changes, want) {
+		t.Errorf("changes of 10001 = %+v, want %+v", d.Changed[0].Changes, want)
+	}
+	want = []FieldChange{{"meetings", "W", "W; R 14:30-17:20 ECS 258"}}
+	if !reflect.DeepEqual(d.Changed[1].Changes, want) {
+		t.Errorf("changes of 10003 = %+v, want %+v", d.Changed[1].Changes, want)
+	}
+
+	var b strings.Builder
+	if err := writeDiff(&b, d, formatText); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"+ CSC 110 A04 (CRN 10004, 202509): Fundamentals of Programming I\n",
+		"- CSC 110 A02 (CRN 10002, 202509): Fundamentals of Programming I\n",
+		"~ CSC 110 A01 (CRN 10001, 202509): room ECS 123 -> ECS 125; instructors Jane Doe -> John Smith; capacity 100 -> 120\n",
+		"1 added, 1 cancelled, 2 changed\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("text diff missing %q:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestDiffExports(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	runMain(t, fake, dir, "-all", "-no-snapshot", "-o", "before.csv")
+	runMain(t, fake, dir, "-all", "-no-snapshot", "-format=json", "-o", "same.json")
+
+	// The same crawl read back from csv and json has no differences
+	out := runMain(t, fake, dir, "-diff", "before.csv", "same.json")
+	if out != "0 added, 0 cancelled, 0 changed\n" {
+		t.Errorf("diff of identical crawls:\n%s", out)
+	}
+
+	fake.mu.Lock()
+	for _, section := range fake.sections["202501"] {
+		if section["courseReferenceNumber"] == "20001" {
+			section["maximumEnrollment"] = 250
+		}
+	}
+	fake.mu.Unlock()
+	runMain(t, fake, dir, "-all", "-no-snapshot", "-format=ndjson", "-o", "after.ndjson")
+
+	runMain(t, fake, dir, "-diff", "-format=markdown", "-o", "diff.md", "before.csv", "after.ndjson")
+	data, err := os.ReadFile(filepath.Join(dir, "diff.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "## Changed (1)") || !strings.Contains(string(data), "| 202501 | 20001 | CSC 110 |") ||
+		!strings.Contains(string(data), "| capacity |") {
+		t.Errorf("markdown diff:\n%s", data)
+	}
+}
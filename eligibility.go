@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// gradeRanks orders UVic letter grades. Pass/fail grades such as COM have
+// no letter and are ranked above every minimum; failing grades rank 0.
+var gradeRanks = map[string]int{
+	"A+": 9, "A": 8, "A-": 7, "B+": 6, "B": 5, "B-": 4, "C+": 3, "C": 2, "D": 1,
+	"F": 0, "E": 0, "N": 0, "NC": 0,
+	"COM": 10, "CR": 10, "P": 10,
+	// In progress courses count as passed, as they do at registration
+	"IP": 10, "INP": 10,
+}
+
+// transcriptEntry is one completed or in progress course. Grade is empty
+// when the transcript doesn't say.
+type transcriptEntry struct {
+	Course string `json:"course"`
+	Grade  string `json:"grade,omitempty"`
+	Term   string `json:"term,omitempty"`
+}
+
+// passed reports whether the course counts towards a requirement with the
+// given minimum grade. Courses without a recorded grade are assumed passed.
+func (e transcriptEntry) passed(minimum string) bool {
+	if e.Grade == "" {
+		return true
+	}
+	rank := gradeRanks[e.Grade]
+	if rank == 0 {
+		return false
+	}
+	if want, ok := gradeRanks[strings.ToUpper(minimum)]; ok {
+		return rank >= want
+	}
+	return true
+}
+
+var (
+	courseIDPattern     = regexp.MustCompile(`^[A-Za-z]{2,4}\d{3}[A-Za-z]?$`)
+	subjectPattern      = regexp.MustCompile(`^[A-Za-z]{2,4}$`)
+	courseNumberPattern = regexp.MustCompile(`^\d{3}[A-Za-z]?$`)
+)
+
+// readTranscript reads one course per line: a course such as CSC110 or
+// "CSC 110", then optionally a grade and a term code, separated by spaces
+// or commas. Blank lines and lines starting with # are skipped. A course
+// taken more than once keeps its best grade.
+func readTranscript(r io.Reader) (map[string]transcriptEntry, error) {
+	transcript := make(map[string]transcriptEntry)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+
+		var entry transcriptEntry
+		switch {
+		case courseIDPattern.MatchString(fields[0]):
+			entry.Course, fields = fields[0], fields[1:]
+		case len(fields) > 1 && subjectPattern.MatchString(fields[0]) && courseNumberPattern.MatchString(fields[1]):
+			entry.Course, fields = fields[0]+fields[1], fields[2:]
+		default:
+			return nil, fmt.Errorf("line %d: %q is not a course", line, fields[0])
+		}
+		entry.Course = strings.ToUpper(entry.Course)
+
+		for _, field := range fields {
+			if _, ok := gradeRanks[strings.ToUpper(field)]; ok && entry.Grade == "" {
+				entry.Grade = strings.ToUpper(field)
+			} else if termCodePattern.MatchString(field) && entry.Term == "" {
+				entry.Term = field
+			} else {
+				return nil, fmt.Errorf("line %d: unexpected %q (want a grade or term code)", line, field)
+			}
+		}
+
+		if prev, ok := transcript[entry.Course]; ok && gradeRanks[prev.Grade] >= gradeRanks[entry.Grade] && prev.Grade != "" {
+			continue
+		}
+		transcript[entry.Course] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading transcript: %v", err)
+	}
+	return transcript, nil
+}
+
+func loadTranscript(path string) (map[string]transcriptEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening transcript: %v", err)
+	}
+	defer f.Close()
+	transcript, err := readTranscript(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return transcript, nil
+}
+
+// Requirement outcomes, from worst to best. A rule that is only missing
+// corequisites can still be met by enrolling in them the same term; one
+// stated in words needs an adviser to check it.
+const (
+	outcomeBlocked = iota
+	outcomeReview
+	outcomeCorequisite
+	outcomeMet
+)
+
+var outcomeNames = []string{"blocked", "needs_review", "needs_corequisite", "eligible"}
+
+type outcome struct {
+	status  int
+	reasons []string
+}
+
+// evaluate checks a rule against a transcript. grade is the minimum grade
+// set by an enclosing rule and concurrent whether courses may be taken in
+// the same term.
+func evaluate(r *Requirement, transcript map[string]transcriptEntry, grade string, concurrent bool) outcome {
+	switch r.Kind {
+	case reqMinGrade:
+		grade = r.Grade
+	case reqConcurrent:
+		concurrent = true
+	case reqCourse:
+		needed := r.Course
+		if grade != "" {
+			needed = grade + " in " + r.Course
+		}
+		entry, taken := transcript[r.Course]
+		switch {
+		case taken && entry.passed(grade):
+			return outcome{status: outcomeMet}
+		case taken:
+			return outcome{outcomeBlocked, []string{fmt.Sprintf("%s (has %s)", needed, entry.Grade)}}
+		case concurrent:
+			return outcome{outcomeCorequisite, []string{needed + " (concurrent)"}}
+		}
+		return outcome{outcomeBlocked, []string{needed}}
+	}
+
+	text := r.Text
+	if grade != "" && r.Kind == reqText {
+		text = grade + " in " + text
+	}
+	if len(r.Children) == 0 {
+		return outcome{outcomeReview, []string{text}}
+	}
+
+	// A group is as good as its Count best children
+	results := make([]outcome, len(r.Children))
+	statuses := make([]int, len(r.Children))
+	for i, child := range r.Children {
+		results[i] = evaluate(child, transcript, grade, concurrent)
+		statuses[i] = results[i].status
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(statuses)))
+	count := r.Count
+	if count <= 0 || count > len(r.Children) {
+		count = len(r.Children)
+	}
+	result := outcome{status: statuses[count-1]}
+
+	var missing []string
+	met := 0
+	for _, child := range results {
+		if child.status == outcomeMet {
+			met++
+			continue
+		}
+		reason := strings.Join(child.reasons, " and ")
+		if len(child.reasons) > 1 && count < len(r.Children) {
+			reason = "(" + reason + ")"
+		}
+		missing = append(missing, reason)
+	}
+	if result.status != outcomeMet {
+		if count == len(r.Children) {
+			result.reasons = missing
+		} else {
+			result.reasons = []string{fmt.Sprintf("%d of %s", count-met, strings.Join(missing, ", "))}
+		}
+	}
+
+	// Kuali only states some groups in words, so their heading still
+	// needs checking when the courses under it are met
+	if r.Kind == reqText && result.status > outcomeReview {
+		result.status = outcomeReview
+		result.reasons = append([]string{text}, result.reasons...)
+	}
+	return result
+}
+
+// Eligibility is whether a student may take a catalog course. Sections and
+// SeatsAvailable are only set when checking a term's offerings.
+type Eligibility struct {
+	Course         string   `json:"course"`
+	Title          string   `json:"title"`
+	Status         string   `json:"status"`
+	Reasons        []string `json:"reasons,omitempty"`
+	Sections       int      `json:"sections,omitempty"`
+	SeatsAvailable int      `json:"seats_available,omitempty"`
+}
+
+// checkEligibility evaluates every catalog course the student hasn't passed,
+// in course order. The graph must already hold every course's rule.
+func (g *PrereqGraph) checkEligibility(transcript map[string]transcriptEntry) []Eligibility {
+	var ids []string
+	for id := range g.catalog {
+		if entry, taken := transcript[id]; !taken || !entry.passed("") {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var results []Eligibility
+	for _, id := range ids {
+		course, _ := g.cached(id)
+		result := outcome{status: outcomeMet}
+		if course.Requirements != nil {
+			result = evaluate(course.Requirements, transcript, "", false)
+		}
+		results = append(results, Eligibility{
+			Course:  id,
+			Title:   g.title(id),
+			Status:  outcomeNames[result.status],
+			Reasons: result.reasons,
+		})
+	}
+	return results
+}
+
+// offeredCourses counts the sections and open seats of every course with a
+// section in term, from a single search of the whole term.
+func offeredCourses(ctx context.Context, session *Session, term string) (map[string]Eligibility, error) {
+	fmt.Fprintf(progress, "Fetching %s offerings\n", termName(term))
+	response, err := session.fetchCourseInfo(ctx, term, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching offerings: %v", err)
+	}
+	offered := make(map[string]Eligibility)
+	for _, section := range response.Data {
+		id := section.Subject + section.CourseNumber
+		o := offered[id]
+		o.Sections++
+		o.SeatsAvailable += max(section.SeatsAvailable, 0)
+		offered[id] = o
+	}
+	return offered, nil
+}
+
+// filterOffered keeps the courses with a section in offered and fills in
+// their section counts.
+func filterOffered(results []Eligibility, offered map[string]Eligibility) []Eligibility {
+	var kept []Eligibility
+	for _, r := range results {
+		if o, ok := offered[r.Course]; ok {
+			r.Sections, r.SeatsAvailable = o.Sections, o.SeatsAvailable
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+var eligibilityHeadings = map[string]string{
+	"eligible":          "Eligible",
+	"needs_corequisite": "Missing only a corequisite",
+	"needs_review":      "Needs review",
+	"blocked":           "Blocked",
+}
+
+// writeEligibility writes the results grouped by status, or as a JSON array.
+func writeEligibility(w io.Writer, results []Eligibility, format string, offered bool) error {
+	switch format {
+	case formatJSON:
+		if results == nil {
+			results = []Eligibility{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case formatText:
+	default:
+		return fmt.Errorf("-eligible writes text or json, not %s", format)
+	}
+
+	var b strings.Builder
+	for i := len(outcomeNames) - 1; i >= 0; i-- {
+		status := outcomeNames[i]
+		var lines []string
+		for _, r := range results {
+			if r.Status != status {
+				continue
+			}
+			line := "  " + strings.TrimSpace(r.Course+" "+r.Title)
+			if offered {
+				line += fmt.Sprintf(" (%d sections, %d seats available)", r.Sections, r.SeatsAvailable)
+			}
+			if len(r.Reasons) > 0 {
+				line += ": " + strings.Join(r.Reasons, "; ")
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s (%d):\n%s\n", eligibilityHeadings[status], len(lines), strings.Join(lines, "\n"))
+	}
+	if b.Len() == 0 {
+		b.WriteString("No courses left to check\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// runEligibility answers -eligible. When term is set only courses offered
+// that term are listed.
+func runEligibility(ctx context.Context, g *PrereqGraph, transcript map[string]transcriptEntry, term, format, dest string) error {
+	if err := g.fetchAll(ctx); err != nil {
+		return err
+	}
+	results := g.checkEligibility(transcript)
+	if term != "" {
+		session, err := NewSession()
+		if err != nil {
+			return fmt.Errorf("error creating session: %v", err)
+		}
+		offered, err := offeredCourses(ctx, session, term)
+		if err != nil {
+			return err
+		}
+		results = filterOffered(results, offered)
+	}
+
+	out, err := openOutput(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := writeEligibility(out, results, format, term != ""); err != nil {
+		return err
+	}
+	return out.Close()
+}
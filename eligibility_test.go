@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTranscript(t *testing.T) {
+	transcript, err := readTranscript(strings.NewReader(`# Jane's courses
+CSC110 B+ 202409
+csc 115, C
+MATH100 F 202409
+MATH100 B 202501
+MATH122 IP
+ENGR110
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]transcriptEntry{
+		"CSC110":  {Course: "CSC110", Grade: "B+", Term: "202409"},
+		"CSC115":  {Course: "CSC115", Grade: "C"},
+		"MATH100": {Course: "MATH100", Grade: "B", Term: "202501"},
+		"MATH122": {Course: "MATH122", Grade: "IP"},
+		"ENGR110": {Course: "ENGR110"},
+	}
+	if !reflect.DeepEqual(transcript, want) {
+		t.Errorf("readTranscript = %v, want %v", transcript, want)
+	}
+
+	if _, err := readTranscript(strings.NewReader("CSC110 excellent\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("bad grade error = %v", err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	course := func(id string) *Requirement { return &Requirement{Kind: reqCourse, Course: id} }
+	// CSC111's rule: C in CSC110, and MATH100 completed or taken alongside
+	rule := &Requirement{Kind: reqAllOf, Count: 2, Children: []*Requirement{
+		{Kind: reqMinGrade, Grade: "C", Count: 1, Children: []*Requirement{course("CSC110")}},
+		{Kind: reqConcurrent, Count: 1, Children: []*Requirement{course("MATH100")}},
+	}}
+	choice := &Requirement{Kind: reqNOf, Count: 1, Children: []*Requirement{
+		{Kind: reqText, Text: "Pre-Calculus 12"},
+		course("MATH120"),
+	}}
+
+	tests := []struct {
+		rule       *Requirement
+		transcript string
+		status     int
+		reasons    []string
+	}{
+		{rule, "CSC110 C\nMATH100 IP", outcomeMet, nil},
+		{rule, "CSC110 B", outcomeCorequisite, []string{"MATH100 (concurrent)"}},
+		{rule, "CSC110 D\nMATH100", outcomeBlocked, []string{"C in CSC110 (has D)"}},
+		{rule, "", outcomeBlocked, []string{"C in CSC110", "MATH100 (concurrent)"}},
+		{choice, "MATH120 A", outcomeMet, nil},
+		{choice, "", outcomeReview, []string{"1 of Pre-Calculus 12, MATH120"}},
+	}
+	for _, tt := range tests {
+		transcript, err := readTranscript(strings.NewReader(tt.transcript))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := evaluate(tt.rule, transcript, "", false)
+		if got.status != tt.status || !reflect.DeepEqual(got.reasons, tt.reasons) {
+			t.Errorf("evaluate with %q = %s %q, want %s %q", tt.transcript,
+				outcomeNames[got.status], got.reasons, outcomeNames[tt.status], tt.reasons)
+		}
+	}
+}
+
+func TestEligible(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "transcript.txt"), []byte("CSC110 C+ 202409\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runMain(t, fake, dir, "-eligible")
+	for _, want := range []string{
+		"Missing only a corequisite (1):\n  CSC111 Fundamentals of Programming with Engineering Applications: MATH100 (concurrent)\n",
+		"Needs review (1):\n  MATH100 Calculus I: 1 of B in Pre-Calculus 12, MATH120; 1 of MATH110 (concurrent), MATH211 (concurrent)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Only MATH100 and CSC110 are offered in 202501, and CSC110 is passed
+	if err := os.WriteFile(filepath.Join(dir, "transcript.txt"), []byte("CSC110 C+\nMATH120 B\nMATH110 IP\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runMain(t, fake, dir, "-eligible", "-offered", "-format=json", "-o", "eligible.json")
+	data, err := os.ReadFile(filepath.Join(dir, "eligible.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []Eligibility
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatalf("%v:\n%s", err, data)
+	}
+	if len(results) != 1 || results[0].Course != "MATH100" || results[0].Status != "eligible" || results[0].Sections == 0 {
+		t.Errorf("offered results = %+v", results)
+	}
+}
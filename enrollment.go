@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// The enrollment store only records a section when its numbers change, so
+// a registration period of hourly polls stays small. polls lists every
+// poll, so a section's numbers are known to hold until its next sample.
+const enrollmentSchema = `
+CREATE TABLE IF NOT EXISTS polls (
+	term     TEXT NOT NULL,
+	taken_at TEXT NOT NULL,
+	PRIMARY KEY (term, taken_at)
+);
+CREATE TABLE IF NOT EXISTS enrollment_sections (
+	term          TEXT NOT NULL,
+	crn           TEXT NOT NULL,
+	subject       TEXT NOT NULL,
+	course_number TEXT NOT NULL,
+	section       TEXT NOT NULL,
+	title         TEXT NOT NULL,
+	PRIMARY KEY (term, crn)
+);
+CREATE TABLE IF NOT EXISTS samples (
+	term               TEXT NOT NULL,
+	crn                TEXT NOT NULL,
+	taken_at           TEXT NOT NULL,
+	enrollment         INTEGER NOT NULL,
+	maximum_enrollment INTEGER NOT NULL,
+	wait_count         INTEGER NOT NULL,
+	wait_capacity      INTEGER NOT NULL,
+	PRIMARY KEY (term, crn, taken_at)
+);
+`
+
+// enrollmentTimeFormat sorts as text, so SQL can order and compare times.
+const enrollmentTimeFormat = "2006-01-02T15:04:05Z"
+
+// enrollmentSample is a section's numbers as of one poll.
+type enrollmentSample struct {
+	Taken             time.Time `json:"time"`
+	Enrollment        int       `json:"enrollment"`
+	MaximumEnrollment int       `json:"maximum_enrollment"`
+	WaitCount         int       `json:"wait_count"`
+	WaitCapacity      int       `json:"wait_capacity"`
+}
+
+func (s enrollmentSample) same(o enrollmentSample) bool {
+	return s.Enrollment == o.Enrollment && s.MaximumEnrollment == o.MaximumEnrollment &&
+		s.WaitCount == o.WaitCount && s.WaitCapacity == o.WaitCapacity
+}
+
+func (s enrollmentSample) full() bool {
+	return s.MaximumEnrollment > 0 && s.Enrollment >= s.MaximumEnrollment
+}
+
+func openEnrollmentDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", path, err)
+	}
+	if _, err := db.Exec(enrollmentSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating tables in %s: %v", path, err)
+	}
+	return db, nil
+}
+
+// enrollmentTerms lists the terms recorded in the store at path, if any.
+func enrollmentTerms(path string) ([]string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+	db, err := openEnrollmentDB(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT DISTINCT term FROM polls`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading polls: %v", err)
+	}
+	defer rows.Close()
+	var terms []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, fmt.Errorf("error reading polls: %v", err)
+		}
+		terms = append(terms, term)
+	}
+	return terms, rows.Err()
+}
+
+// enrollmentRecorder writes polls of one term to the store.
+type enrollmentRecorder struct {
+	db   *sql.DB
+	term string
+	last map[string]enrollmentSample
+}
+
+// newEnrollmentRecorder loads each section's latest sample, so a restarted
+// collection only records what changed since.
+func newEnrollmentRecorder(db *sql.DB, term string) (*enrollmentRecorder, error) {
+	r := &enrollmentRecorder{db: db, term: term, last: make(map[string]enrollmentSample)}
+	rows, err := db.Query(`SELECT crn, enrollment, maximum_enrollment, wait_count, wait_capacity FROM samples s
+		WHERE term = ? AND taken_at = (SELECT MAX(taken_at) FROM samples WHERE term = s.term AND crn = s.crn)`, term)
+	if err != nil {
+		return nil, fmt.Errorf("error reading samples: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var crn string
+		var s enrollmentSample
+		if err := rows.Scan(&crn, &s.Enrollment, &s.MaximumEnrollment, &s.WaitCount, &s.WaitCapacity); err != nil {
+			return nil, fmt.Errorf("error reading samples: %v", err)
+		}
+		r.last[crn] = s
+	}
+	return r, rows.Err()
+}
+
+// record stores one poll and returns how many sections changed.
+func (r *enrollmentRecorder) record(sections []CourseSection, taken time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	at := taken.UTC().Format(enrollmentTimeFormat)
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO polls (term, taken_at) VALUES (?, ?)`, r.term, at); err != nil {
+		return 0, fmt.Errorf("error recording poll: %v", err)
+	}
+	pending := make(map[string]enrollmentSample)
+	for _, section := range sections {
+		crn := section.CourseReferenceNumber
+		sample := enrollmentSample{
+			Enrollment:        section.Enrollment,
+			MaximumEnrollment: section.MaximumEnrollment,
+			WaitCount:         section.WaitCount,
+			WaitCapacity:      section.WaitCapacity,
+		}
+		if prev, ok := r.last[crn]; ok && prev.same(sample) {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO enrollment_sections (term, crn, subject, course_number, section, title)
+			VALUES (?, ?, ?, ?, ?, ?)`, r.term, crn, section.Subject, section.CourseNumber, section.Section, section.CourseTitle); err != nil {
+			return 0, fmt.Errorf("error recording section %s: %v", crn, err)
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO samples (term, crn, taken_at, enrollment, maximum_enrollment, wait_count, wait_capacity)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, r.term, crn, at, sample.Enrollment, sample.MaximumEnrollment, sample.WaitCount, sample.WaitCapacity); err != nil {
+			return 0, fmt.Errorf("error recording sample of %s: %v", crn, err)
+		}
+		pending[crn] = sample
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing samples: %v", err)
+	}
+	for crn, sample := range pending {
+		r.last[crn] = sample
+	}
+	return len(pending), nil
+}
+
+// collectEnrollment polls term every interval, or polls times if that is
+// positive, searching each subject or else the whole term.
+func collectEnrollment(ctx context.Context, session *Session, r *enrollmentRecorder, subjects []string, interval time.Duration, polls int) {
+	if len(subjects) == 0 {
+		subjects = []string{""}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for n := 1; ; n++ {
+		var sections []CourseSection
+		var err error
+		for _, subject := range subjects {
+			for section, searchErr := range session.searchSections(ctx, r.term, subject, "", nil) {
+				if searchErr != nil {
+					err = searchErr
+					break
+				}
+				sections = append(sections, section)
+			}
+			if err != nil {
+				break
+			}
+		}
+
+		// A partial poll would look like sections vanishing, so skip it
+		if err != nil {
+			fmt.Fprintf(progress, "Error polling %s, skipping this poll: %v\n", termName(r.term), err)
+		} else if changed, err := r.record(sections, time.Now()); err != nil {
+			fmt.Fprintf(progress, "Error recording poll: %v\n", err)
+		} else {
+			fmt.Fprintf(progress, "%s recorded %d sections, %d changed\n", time.Now().Format(time.DateTime), len(sections), changed)
+		}
+
+		if polls > 0 && n >= polls {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// curvePoint is a section's numbers at the end of one day.
+type curvePoint struct {
+	Date              string `json:"date"`
+	Enrollment        int    `json:"enrollment"`
+	MaximumEnrollment int    `json:"maximum_enrollment"`
+	WaitCount         int    `json:"wait_count"`
+}
+
+// EnrollmentReport summarizes one section over the registration period.
+type EnrollmentReport struct {
+	Term              string       `json:"term"`
+	CRN               string       `json:"crn"`
+	Subject           string       `json:"subject"`
+	CourseNumber      string       `json:"course_number"`
+	Section           string       `json:"section"`
+	Title             string       `json:"title"`
+	Enrollment        int          `json:"enrollment"`
+	MaximumEnrollment int          `json:"maximum_enrollment"`
+	FilledAt          *time.Time   `json:"filled_at,omitempty"`
+	PeakWaitCount     int          `json:"peak_wait_count"`
+	PeakWaitAt        *time.Time   `json:"peak_wait_at,omitempty"`
+	WaitCapacity      int          `json:"wait_capacity"`
+	Curve             []curvePoint `json:"curve"`
+}
+
+// enrollmentReports builds a report for each recorded section of term,
+// optionally only those of one subject or course. Curves have a point for
+// every day from the first poll to the last, in the local time zone.
+func enrollmentReports(db *sql.DB, term, subject, number string) ([]EnrollmentReport, error) {
+	var first, last sql.NullString
+	if err := db.QueryRow(`SELECT MIN(taken_at), MAX(taken_at) FROM polls WHERE term = ?`, term).Scan(&first, &last); err != nil {
+		return nil, fmt.Errorf("error reading polls: %v", err)
+	}
+	if !first.Valid {
+		return nil, fmt.Errorf("no enrollment recorded for %s (see -collect)", termName(term))
+	}
+	start, _ := time.Parse(enrollmentTimeFormat, first.String)
+	end, _ := time.Parse(enrollmentTimeFormat, last.String)
+
+	query := `SELECT s.crn, s.subject, s.course_number, s.section, s.title,
+		p.taken_at, p.enrollment, p.maximum_enrollment, p.wait_count, p.wait_capacity
+		FROM enrollment_sections s JOIN samples p ON p.term = s.term AND p.crn = s.crn
+		WHERE s.term = ?`
+	args := []interface{}{term}
+	if subject != "" {
+		query += ` AND s.subject = ?`
+		args = append(args, subject)
+	}
+	if number != "" {
+		query += ` AND s.course_number = ?`
+		args = append(args, number)
+	}
+	query += ` ORDER BY s.subject, s.course_number, s.section, s.crn, p.taken_at`
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error reading samples: %v", err)
+	}
+	defer rows.Close()
+
+	var reports []EnrollmentReport
+	var samples [][]enrollmentSample
+	for rows.Next() {
+		var r EnrollmentReport
+		var at string
+		var s enrollmentSample
+		if err := rows.Scan(&r.CRN, &r.Subject, &r.CourseNumber, &r.Section, &r.Title,
+			&at, &s.Enrollment, &s.MaximumEnrollment, &s.WaitCount, &s.WaitCapacity); err != nil {
+			return nil, fmt.Errorf("error reading samples: %v", err)
+		}
+		s.Taken, _ = time.Parse(enrollmentTimeFormat, at)
+		if n := len(reports); n == 0 || reports[n-1].CRN != r.CRN {
+			r.Term = term
+			reports = append(reports, r)
+			samples = append(samples, nil)
+		}
+		samples[len(samples)-1] = append(samples[len(samples)-1], s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading samples: %v", err)
+	}
+
+	for i := range reports {
+		summarizeEnrollment(&reports[i], samples[i], start, end)
+	}
+	return reports, nil
+}
+
+// summarizeEnrollment fills in a report from a section's samples, oldest
+// first.
+func summarizeEnrollment(r *EnrollmentReport, samples []enrollmentSample, start, end time.Time) {
+	for _, s := range samples {
+		if s.full() && r.FilledAt == nil {
+			taken := s.Taken
+			r.FilledAt = &taken
+		}
+		if s.WaitCount > r.PeakWaitCount {
+			taken := s.Taken
+			r.PeakWaitCount, r.PeakWaitAt = s.WaitCount, &taken
+		}
+	}
+	latest := samples[len(samples)-1]
+	r.Enrollment, r.MaximumEnrollment, r.WaitCapacity = latest.Enrollment, latest.MaximumEnrollment, latest.WaitCapacity
+
+	r.Curve = []curvePoint{}
+	next := 0
+	var current *enrollmentSample
+	day := time.Date(start.Local().Year(), start.Local().Month(), start.Local().Day(), 0, 0, 0, 0, time.Local)
+	for !day.After(end) {
+		endOfDay := day.AddDate(0, 0, 1)
+		for next < len(samples) && samples[next].Taken.Before(endOfDay) {
+			current = &samples[next]
+			next++
+		}
+		if current != nil {
+			r.Curve = append(r.Curve, curvePoint{
+				Date:              day.Format(time.DateOnly),
+				Enrollment:        current.Enrollment,
+				MaximumEnrollment: current.MaximumEnrollment,
+				WaitCount:         current.WaitCount,
+			})
+		}
+		day = endOfDay
+	}
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// fillCurve draws the daily fill rate as a sparkline from empty to full.
+func fillCurve(points []curvePoint) string {
+	var b strings.Builder
+	for _, p := range points {
+		rate := 0.0
+		if p.MaximumEnrollment > 0 {
+			rate = min(float64(p.Enrollment)/float64(p.MaximumEnrollment), 1)
+		}
+		b.WriteRune(sparkBlocks[int(rate*float64(len(sparkBlocks)-1)+0.5)])
+	}
+	return b.String()
+}
+
+// writeEnrollmentReport writes the reports as aligned text, Markdown or
+// JSON.
+func writeEnrollmentReport(w io.Writer, reports []EnrollmentReport, format string) error {
+	when := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Local().Format("2006-01-02 15:04")
+	}
+
+	var b strings.Builder
+	switch format {
+	case formatJSON:
+		if reports == nil {
+			reports = []EnrollmentReport{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+
+	case formatText:
+		for _, r := range reports {
+			fmt.Fprintf(&b, "%s %s %s (CRN %s) %s\n", r.Subject, r.CourseNumber, r.Section, r.CRN, r.Title)
+			fmt.Fprintf(&b, "  enrolled %d/%d, filled %s, peak waitlist %d/%d (%s)\n",
+				r.Enrollment, r.MaximumEnrollment, when(r.FilledAt), r.PeakWaitCount, r.WaitCapacity, when(r.PeakWaitAt))
+			if len(r.Curve) > 0 {
+				fmt.Fprintf(&b, "  %s %s %s\n", r.Curve[0].Date, fillCurve(r.Curve), r.Curve[len(r.Curve)-1].Date)
+			}
+		}
+		if len(reports) == 0 {
+			b.WriteString("No sections recorded\n")
+		}
+
+	case formatMarkdown:
+		b.WriteString("| Course | Section | CRN | Enrolled | Filled | Peak waitlist | Fill curve |\n| --- | --- | --- | --- | --- | --- | --- |\n")
+		for _, r := range reports {
+			fmt.Fprintf(&b, "| %s %s | %s | %s | %d/%d | %s | %d/%d | %s |\n", r.Subject, r.CourseNumber, r.Section, r.CRN,
+				r.Enrollment, r.MaximumEnrollment, when(r.FilledAt), r.PeakWaitCount, r.WaitCapacity, fillCurve(r.Curve))
+		}
+
+	default:
+		return fmt.Errorf("-enrollment-report writes text, json or markdown, not %s", format)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// collectSubjects uppercases and dedupes the subjects given to -collect.
+func collectSubjects(args []string) []string {
+	seen := make(map[string]bool)
+	var subjects []string
+	for _, arg := range args {
+		subject := strings.ToUpper(arg)
+		if !seen[subject] {
+			seen[subject] = true
+			subjects = append(subjects, subject)
+		}
+	}
+	sort.Strings(subjects)
+	return subjects
+}
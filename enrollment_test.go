@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnrollmentReports(t *testing.T) {
+	db, err := openEnrollmentDB(filepath.Join(scratchDir(t), "enrollment.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r, err := newEnrollmentRecorder(db, "202509")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	section := func(enrollment, waitCount int) CourseSection {
+		return CourseSection{
+			CourseReferenceNumber: "10001", Subject: "CSC", CourseNumber: "110", Section: "A01",
+			CourseTitle: "Fundamentals of Programming I", Enrollment: enrollment, MaximumEnrollment: 100,
+			WaitCount: waitCount, WaitCapacity: 20,
+		}
+	}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local)
+	polls := []struct {
+		at      time.Time
+		section CourseSection
+		changed int
+	}{
+		{start, section(40, 0), 1},
+		{start.Add(time.Hour), section(40, 0), 0},
+		{start.AddDate(0, 0, 2), section(100, 5), 1},
+		{start.AddDate(0, 0, 3), section(99, 3), 1},
+	}
+	for _, p := range polls {
+		changed, err := r.record([]CourseSection{p.section}, p.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != p.changed {
+			t.Errorf("poll at %v changed %d sections, want %d", p.at, changed, p.changed)
+		}
+	}
+
+	reports, err := enrollmentReports(db, "202509", "CSC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("reports = %+v", reports)
+	}
+	got := reports[0]
+	if got.FilledAt == nil || !got.FilledAt.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("filled at %v", got.FilledAt)
+	}
+	if got.PeakWaitCount != 5 || got.Enrollment != 99 {
+		t.Errorf("peak waitlist %d, enrollment %d", got.PeakWaitCount, got.Enrollment)
+	}
+	// The section held at 40 through the day without a sample
+	if len(got.Curve) != 4 || got.Curve[1].Enrollment != 40 || got.Curve[1].Date != "2025-06-02" {
+		t.Errorf("curve = %+v", got.Curve)
+	}
+	if curve := fillCurve(got.Curve); curve != "▄▄██" {
+		t.Errorf("fill curve = %q", curve)
+	}
+
+	// A recorder restarted on the same store only records changes
+	r, err = newEnrollmentRecorder(db, "202509")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := r.record([]CourseSection{section(99, 3)}, start.AddDate(0, 0, 4)); changed != 0 {
+		t.Errorf("restarted recorder changed %d sections", changed)
+	}
+}
+
+func TestCollectEnrollment(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	runMain(t, fake, dir, "-collect", "-polls=1", "CSC")
+
+	fake.mu.Lock()
+	for _, section := range fake.sections["202501"] {
+		if section["courseReferenceNumber"] == "20001" {
+			section["enrollment"] = 200
+			section["waitCount"] = 4
+		}
+	}
+	fake.mu.Unlock()
+	runMain(t, fake, dir, "-collect", "-polls=1", "CSC")
+
+	out := runMain(t, fake, dir, "-enrollment-report", "-format=json", "CSC", "110")
+	var reports []EnrollmentReport
+	if err := json.Unmarshal([]byte(out), &reports); err != nil {
+		t.Fatalf("%v:\n%s", err, out)
+	}
+	if len(reports) != 5 {
+		t.Fatalf("got %d sections, want CSC 110's 5", len(reports))
+	}
+	for _, r := range reports {
+		if r.CRN == "20001" && (r.FilledAt == nil || r.PeakWaitCount != 4 || r.Enrollment != 200) {
+			t.Errorf("CRN 20001 report = %+v", r)
+		}
+		if r.CRN == "20012" && r.FilledAt != nil {
+			t.Errorf("CRN 20012 never filled, report = %+v", r)
+		}
+	}
+
+	out = runMain(t, fake, dir, "-enrollment-report", "MATH")
+	if !strings.Contains(out, "No sections recorded") {
+		t.Errorf("MATH was never collected:\n%s", out)
+	}
+}
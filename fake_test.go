@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCatalogID is the Kuali catalog the fake reports as current.
+const fakeCatalogID = "65eb47906641d7001c157bc4"
+
+// fakePageSize mimics Banner capping pageMaxSize, small enough that the
+// fixture courses need several pages.
+const fakePageSize = 2
+
+// fakeUVic serves the subset of Banner and Kuali the scraper uses from the
+// JSON fixtures in testdata.
+type fakeUVic struct {
+	*httptest.Server
+
+	sections map[string][]map[string]interface{} // term -> sections
+	meetings map[string]json.RawMessage          // CRN -> getFacultyMeetingTimes payload
+	kuali    map[string]json.RawMessage          // pid -> catalog course
+	catalog  []json.RawMessage                   // Kuali course list
+	terms    []Term
+
+	mu       sync.Mutex
+	sessions map[string]string // session cookie -> selected term
+	nextID   int
+	hits     map[string]int // path -> request count, and "rejected" for unbound requests
+
+	// latency delays meeting time requests before they check the session,
+	// widening the window for the session to be rebound under them
+	latency time.Duration
+}
+
+func loadFixture(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("reading fixture %s: %v", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decoding fixture %s: %v", name, err)
+	}
+}
+
+func newFakeUVic(t *testing.T) *fakeUVic {
+	t.Helper()
+	f := &fakeUVic{sessions: make(map[string]string), hits: make(map[string]int)}
+	loadFixture(t, "banner_sections.json", &f.sections)
+	loadFixture(t, "banner_meetings.json", &f.meetings)
+	loadFixture(t, "kuali_courses.json", &f.kuali)
+	loadFixture(t, "banner_terms.json", &f.terms)
+	loadFixture(t, "kuali_catalog.json", &f.catalog)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /StudentRegistrationSsb/ssb/term/termSelection", f.termSelection)
+	mux.HandleFunc("POST /StudentRegistrationSsb/ssb/term/search", f.termSearch)
+	mux.HandleFunc("POST /StudentRegistrationSsb/ssb/classSearch/resetDataForm", f.resetDataForm)
+	mux.HandleFunc("GET /StudentRegistrationSsb/ssb/classSearch/getTerms", f.getTerms)
+	mux.HandleFunc("GET /StudentRegistrationSsb/ssb/searchResults/searchResults", f.searchResults)
+	mux.HandleFunc("GET /StudentRegistrationSsb/ssb/searchResults/getFacultyMeetingTimes", f.facultyMeetingTimes)
+	mux.HandleFunc("GET /api/v1/catalog/public/catalogs/current", f.kualiCurrentCatalog)
+	mux.HandleFunc("GET /api/v1/catalog/courses/{catalog}", f.kualiCourses)
+	mux.HandleFunc("GET /api/v1/catalog/course/{catalog}/{pid}", f.kualiCourse)
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeUVic) bannerURL() string {
+	return f.URL + "/StudentRegistrationSsb/ssb"
+}
+
+func (f *fakeUVic) kualiURL() string {
+	return f.URL + "/api/v1"
+}
+
+func (f *fakeUVic) hit(r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hits[r.URL.Path]++
+}
+
+func (f *fakeUVic) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hits[path]
+}
+
+// expireSessions forgets every session, as Banner does after inactivity.
+func (f *fakeUVic) expireSessions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions = make(map[string]string)
+}
+
+// sessionTerm returns the term bound to the request's session, if any.
+func (f *fakeUVic) sessionTerm(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie("JSESSIONID")
+	if err != nil {
+		return "", false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	term, ok := f.sessions[cookie.Value]
+	return term, ok && term != ""
+}
+
+// rejectUnbound answers like Banner does for a missing or expired session:
+// a redirect to the term selection page.
+func (f *fakeUVic) rejectUnbound(w http.ResponseWriter, r *http.Request, term string) bool {
+	bound, ok := f.sessionTerm(r)
+	if ok && bound == term {
+		return false
+	}
+	f.mu.Lock()
+	f.hits["rejected"]++
+	f.mu.Unlock()
+	http.Redirect(w, r, "/StudentRegistrationSsb/ssb/term/termSelection?mode=search", http.StatusFound)
+	return true
+}
+
+func (f *fakeUVic) termSelection(w http.ResponseWriter, r *http.Request) {
+	f.hit(r)
+	if _, err := r.Cookie("JSESSIONID"); err != nil {
+		f.mu.Lock()
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.sessions[id] = ""
+		f.mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: id, Path: "/"})
+	}
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	fmt.Fprint(w, "<html><body>Select a term</body></html>")
+}
+
+func (f *fakeUVic) termSearch(w http.ResponseWriter, r *http.Request) {
+	f.hit(r)
+	cookie, err := r.Cookie("JSESSIONID")
+	if err != nil {
+		http.Error(w, "no session", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.sessions[cookie.Value] = r.PostForm.Get("term")
+	f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"fwdURL":"/StudentRegistrationSsb/ssb/classSearch/classSearch"}`)
+}
+
+func (f *fakeUVic) resetDataForm(w http.ResponseWriter, r *http.Request) {
+	f.hit(r)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, "true")
+}
+
+// getTerms pages like Banner: offset is a 1-based page number.
+func (f *fakeUVic) getTerms(w http.ResponseWriter, r *http.Request) {
+	f.hit(r)
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("offset"))
+	size, _ := strconv.Atoi(q.Get("max"))
+	start := min(max(page-1, 0)*size, len(f.terms))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f.terms[start:min(start+size, len(f.terms))])
+}
+
+func (f *fakeUVic) searchResults(w http.ResponseWriter, r *http.Request) {
+	f.hit(r)
+	q := r.URL.Query()
+	term := q.Get("txt_term")
+	if f.rejectUnbound(w, r, term) {
+		return
+	}
+
+	var matched []map[string]interface{}
+	for _, section := range f.sections[term] {
+		if subject := q.Get("txt_subject"); subject != "" && section["subject"] != subject {
+			continue
+		}
+		if number := q.Get("txt_courseNumber"); number != "" && section["courseNumber"] != number {
+			continue
+		}
+		if crn := q.Get("txt_courseReferenceNumber"); crn != "" && section["courseReferenceNumber"] != crn {
+			continue
+		}
+		matched = append(matched, section)
+	}
+
+	offset, _ := strconv.Atoi(q.Get("pageOffset"))
+	size, _ := strconv.Atoi(q.Get("pageMaxSize"))
+	if size <= 0 || size > fakePageSize {
+		size = fakePageSize
+	}
+	page := []map[string]interface{}{}
+	if offset < len(matched) {
+		page = matched[offset:min(offset+size, len(matched))]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"totalCount": len(matched),
+		"data":       page,
+	})
+}
+
+func (f *fakeUVic) facultyMeetingTimes(w http.ResponseWriter, r *http.Request) {
+	f.hit(r)
+	time.Sleep(f.latency)
+	q := r.URL.Query()
+	if f.rejectUnbound(w, r, q.Get("term")) {
+		return
+	}
+	payload, ok := f.meetings[q.Get("courseReferenceNumber")]
+	if !ok {
+		payload = json.RawMessage(`{"fmt":[]}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+func (f *fakeUVic) kualiCurrentCatalog(w http.ResponseWriter, r *http.Request) {
+	f.hit(r)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"_id":%q,"title":"2025-2026 Undergraduate Calendar"}`, fakeCatalogID)
+}
+
+func (f *fakeUVic) kualiCourses(w http.ResponseWriter, r *http.Request) {
+	f.hit(r)
+	if r.PathValue("catalog") != fakeCatalogID {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	skip, _ := strconv.Atoi(q.Get("skip"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	// Page far smaller than asked so the client has to keep going
+	limit = min(max(limit, 1), fakePageSize)
+	start := min(skip, len(f.catalog))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f.catalog[start:min(start+limit, len(f.catalog))])
+}
+
+func (f *fakeUVic) kualiCourse(w http.ResponseWriter, r *http.Request) {
+	f.hit(r)
+	course, ok := f.kuali[r.PathValue("pid")]
+	if !ok || r.PathValue("catalog") != fakeCatalogID {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(course)
+}
+
+// scratchDir returns a working directory holding the fixture catalog as
+// courses.json.
+func scratchDir(t *testing.T) string {
+	t.Helper()
+	catalog, err := os.ReadFile(filepath.Join("testdata", "courses.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "courses.json"), catalog, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// runMain runs the command line with args inside dir, pointed at the fake,
+// and returns everything it printed.
+func runMain(t *testing.T, fake *fakeUVic, dir string, args ...string) string {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		printed, _ := io.ReadAll(r)
+		output <- string(printed)
+	}()
+
+	// main registers its flags on the global flag set and rewrites
+	// package-level configuration, so reset both around each run.
+	savedBanner, savedKuali, savedCatalog := bannerBaseURL, kualiBaseURL, kualiCatalogID
+	savedTransport, savedCache := sharedTransport, termsCachePath
+	defer func() {
+		bannerBaseURL, kualiBaseURL, kualiCatalogID = savedBanner, savedKuali, savedCatalog
+		sharedTransport, termsCachePath = savedTransport, savedCache
+	}()
+	flag.CommandLine = flag.NewFlagSet("vikes-scraper", flag.ContinueOnError)
+	os.Args = append([]string{
+		"vikes-scraper",
+		"-banner-url=" + fake.bannerURL(),
+		"-kuali-url=" + fake.kualiURL(),
+		"-rate=0",
+		"-semester=202501",
+		"-terms-cache=terms.json",
+	}, args...)
+
+	main()
+
+	w.Close()
+	return <-output
+}
@@ -0,0 +1,627 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prereqCacheTTL is how long fetched prerequisite rules are reused. They
+// only change when a new catalog is published.
+const prereqCacheTTL = 7 * 24 * time.Hour
+
+// graphCourse is a catalog course, its description as plain text and its
+// parsed prerequisite rule.
+type graphCourse struct {
+	ID           string       `json:"id"`
+	Title        string       `json:"title"`
+	Description  string       `json:"description,omitempty"`
+	Requirements *Requirement `json:"requirements,omitempty"`
+}
+
+type prereqCache struct {
+	CatalogID string                 `json:"catalog_id"`
+	Fetched   time.Time              `json:"fetched"`
+	Courses   map[string]graphCourse `json:"courses"`
+}
+
+// prereqEdge says From is required for To. Concurrent edges may also be
+// met by enrolling in From in the same term.
+type prereqEdge struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Concurrent bool   `json:"concurrent,omitempty"`
+}
+
+// PrereqGraph is the prerequisite graph of the courses in courses.json.
+// Rules are fetched from Kuali on demand and cached on disk.
+type PrereqGraph struct {
+	path      string
+	catalogID string
+	catalog   map[string]Course
+
+	mu      sync.Mutex
+	courses map[string]graphCourse
+	fetched time.Time
+	dirty   bool
+}
+
+func loadPrereqGraph(ctx context.Context, catalog []Course, path string) (*PrereqGraph, error) {
+	catalogID, err := currentKualiCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g := &PrereqGraph{
+		path:      path,
+		catalogID: catalogID,
+		catalog:   make(map[string]Course),
+		courses:   make(map[string]graphCourse),
+		fetched:   time.Now(),
+	}
+	for _, c := range catalog {
+		g.catalog[c.CourseID] = c
+	}
+
+	cache, err := readPrereqCache(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return g, nil
+		}
+		fmt.Fprintf(progress, "Warning: ignoring prerequisite cache: %v\n", err)
+		return g, nil
+	}
+	if cache.CatalogID == catalogID && time.Since(cache.Fetched) < prereqCacheTTL {
+		g.courses, g.fetched = cache.Courses, cache.Fetched
+	}
+	return g, nil
+}
+
+// readPrereqCache reads the cache whatever its age or catalog.
+func readPrereqCache(path string) (*prereqCache, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cache prereqCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %v", path, err)
+	}
+	return &cache, nil
+}
+
+// save writes newly fetched rules back to the cache.
+func (g *PrereqGraph) save() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.dirty {
+		return nil
+	}
+	data, err := json.Marshal(prereqCache{CatalogID: g.catalogID, Fetched: g.fetched, Courses: g.courses})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(g.path, data, 0644); err != nil {
+		return fmt.Errorf("error writing prerequisite cache: %v", err)
+	}
+	g.dirty = false
+	return nil
+}
+
+func (g *PrereqGraph) cached(id string) (graphCourse, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, ok := g.courses[id]
+	return c, ok
+}
+
+// fetch returns a course's rule, fetching it from Kuali if needed. ok is
+// false for courses that are not in the catalog.
+func (g *PrereqGraph) fetch(ctx context.Context, id string) (graphCourse, bool, error) {
+	if c, ok := g.cached(id); ok {
+		return c, true, nil
+	}
+	course, ok := g.catalog[id]
+	if !ok {
+		return graphCourse{}, false, nil
+	}
+
+	info, err := fetchKualiCourseInfo(ctx, course.PID)
+	if err != nil {
+		return graphCourse{}, false, fmt.Errorf("error fetching %s: %v", id, err)
+	}
+	requirements, err := parsePrerequisites(info.PreOrCorequisites)
+	if err != nil {
+		return graphCourse{}, false, fmt.Errorf("error reading prerequisites of %s: %v", id, err)
+	}
+	c := graphCourse{ID: id, Title: course.Title, Description: plainText(info.Description), Requirements: requirements}
+
+	g.mu.Lock()
+	g.courses[id] = c
+	g.dirty = true
+	g.mu.Unlock()
+	return c, true, nil
+}
+
+// fetchAll fetches the rule of every catalog course not already cached.
+func (g *PrereqGraph) fetchAll(ctx context.Context) error {
+	var missing []string
+	for id := range g.catalog {
+		if _, ok := g.cached(id); !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	fmt.Fprintf(progress, "Fetching prerequisites of %d courses\n", len(missing))
+
+	ids := make(chan string)
+	errs := make(chan error, len(missing))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				if _, _, err := g.fetch(ctx, id); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for _, id := range missing {
+		ids <- id
+	}
+	close(ids)
+	wg.Wait()
+	close(errs)
+
+	// Keep what was fetched even if some courses failed
+	saveErr := g.save()
+	if err, ok := <-errs; ok {
+		return err
+	}
+	return saveErr
+}
+
+// requiredCourses lists the courses a rule mentions, in rule order.
+func requiredCourses(r *Requirement, concurrent bool, visit func(id string, concurrent bool)) {
+	if r == nil {
+		return
+	}
+	if r.Kind == reqCourse {
+		visit(r.Course, concurrent)
+		return
+	}
+	for _, child := range r.Children {
+		requiredCourses(child, concurrent || r.Kind == reqConcurrent, visit)
+	}
+}
+
+// closure fetches every course reachable through id's prerequisites and
+// returns them, excluding id, in sorted order.
+func (g *PrereqGraph) closure(ctx context.Context, id string) ([]string, error) {
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		course, ok, err := g.fetch(ctx, queue[0])
+		queue = queue[1:]
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		requiredCourses(course.Requirements, false, func(req string, _ bool) {
+			if !seen[req] {
+				seen[req] = true
+				queue = append(queue, req)
+			}
+		})
+	}
+	delete(seen, id)
+
+	var ids []string
+	for req := range seen {
+		ids = append(ids, req)
+	}
+	sort.Strings(ids)
+	return ids, g.save()
+}
+
+// edges returns every prerequisite edge between fetched courses.
+func (g *PrereqGraph) edges() []prereqEdge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var edges []prereqEdge
+	for id, course := range g.courses {
+		seen := make(map[string]bool)
+		requiredCourses(course.Requirements, false, func(req string, concurrent bool) {
+			if !seen[req] {
+				seen[req] = true
+				edges = append(edges, prereqEdge{From: req, To: id, Concurrent: concurrent})
+			}
+		})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// unlocks returns the courses that directly require id, and every course
+// that requires it through a chain of prerequisites.
+func unlocks(edges []prereqEdge, id string) (direct []prereqEdge, eventually []string) {
+	next := make(map[string][]string)
+	for _, e := range edges {
+		next[e.From] = append(next[e.From], e.To)
+		if e.From == id {
+			direct = append(direct, e)
+		}
+	}
+
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		for _, to := range next[queue[0]] {
+			if !seen[to] {
+				seen[to] = true
+				eventually = append(eventually, to)
+				queue = append(queue, to)
+			}
+		}
+		queue = queue[1:]
+	}
+	sort.Strings(eventually)
+	return direct, eventually
+}
+
+// findCycles returns each group of courses that require one another,
+// using Tarjan's strongly connected components.
+func findCycles(edges []prereqEdge) [][]string {
+	next := make(map[string][]string)
+	var nodes []string
+	seenNode := make(map[string]bool)
+	for _, e := range edges {
+		next[e.From] = append(next[e.From], e.To)
+		for _, n := range []string{e.From, e.To} {
+			if !seenNode[n] {
+				seenNode[n] = true
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	sort.Strings(nodes)
+
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+
+	var connect func(n string)
+	connect = func(n string) {
+		index[n] = len(index)
+		low[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+
+		selfLoop := false
+		for _, m := range next[n] {
+			if m == n {
+				selfLoop = true
+			}
+			if _, ok := index[m]; !ok {
+				connect(m)
+				low[n] = min(low[n], low[m])
+			} else if onStack[m] {
+				low[n] = min(low[n], index[m])
+			}
+		}
+
+		if low[n] == index[n] {
+			var component []string
+			for {
+				m := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[m] = false
+				component = append(component, m)
+				if m == n {
+					break
+				}
+			}
+			if len(component) > 1 || selfLoop {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+	for _, n := range nodes {
+		if _, ok := index[n]; !ok {
+			connect(n)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// orphans returns required courses that are missing from the catalog,
+// with the courses that require them.
+func (g *PrereqGraph) orphans(edges []prereqEdge) map[string][]string {
+	missing := make(map[string][]string)
+	for _, e := range edges {
+		if _, ok := g.catalog[e.From]; !ok {
+			missing[e.From] = append(missing[e.From], e.To)
+		}
+	}
+	return missing
+}
+
+func (g *PrereqGraph) title(id string) string {
+	if c, ok := g.catalog[id]; ok {
+		return c.Title
+	}
+	return ""
+}
+
+// renderChain writes id's rule with the rule of every required course
+// expanded beneath it. Courses already expanded, on a cycle or missing
+// from the catalog are marked instead.
+func (g *PrereqGraph) renderChain(w io.Writer, id string) {
+	c, _ := g.cached(id)
+	fmt.Fprintf(w, "%s %s\n", id, c.Title)
+	if c.Requirements == nil {
+		fmt.Fprintln(w, "No prerequisites")
+		return
+	}
+	shown := map[string]bool{id: true}
+	g.renderExpanded(w, c.Requirements, 0, shown, map[string]bool{id: true})
+}
+
+func (g *PrereqGraph) renderExpanded(w io.Writer, r *Requirement, depth int, shown, path map[string]bool) {
+	indent := strings.Repeat("  ", depth)
+	if r.Kind != reqCourse {
+		if len(r.Children) == 0 {
+			fmt.Fprintf(w, "%s• %s\n", indent, r.label())
+			return
+		}
+		fmt.Fprintf(w, "%s%s\n", indent, r.label())
+		for _, child := range r.Children {
+			g.renderExpanded(w, child, depth+1, shown, path)
+		}
+		return
+	}
+
+	course, ok := g.cached(r.Course)
+	switch {
+	case path[r.Course]:
+		fmt.Fprintf(w, "%s• %s (circular)\n", indent, r.label())
+	case !ok:
+		fmt.Fprintf(w, "%s• %s (not in courses.json)\n", indent, r.label())
+	case shown[r.Course]:
+		fmt.Fprintf(w, "%s• %s (see above)\n", indent, r.label())
+	default:
+		fmt.Fprintf(w, "%s• %s\n", indent, r.label())
+		shown[r.Course] = true
+		if course.Requirements != nil {
+			path[r.Course] = true
+			g.renderExpanded(w, course.Requirements, depth+1, shown, path)
+			delete(path, r.Course)
+		}
+	}
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// writeDOT writes the graph for Graphviz. Arrows point from a course to
+// the courses it unlocks; corequisites are dashed, courses missing from
+// the catalog are grey and edges within a cycle are red.
+func (g *PrereqGraph) writeDOT(w io.Writer, edges []prereqEdge, cycles [][]string) error {
+	inCycle := make(map[string]int)
+	for i, cycle := range cycles {
+		for _, id := range cycle {
+			inCycle[id] = i + 1
+		}
+	}
+	missing := g.orphans(edges)
+
+	var b strings.Builder
+	b.WriteString("digraph prerequisites {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	var ids []string
+	for id := range g.catalog {
+		ids = append(ids, id)
+	}
+	for id := range missing {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		label := id
+		if title := g.title(id); title != "" {
+			label += `\n` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(title)
+		}
+		attrs := `label="` + label + `"`
+		if _, ok := missing[id]; ok {
+			attrs += ", style=dashed, color=grey"
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", dotQuote(id), attrs)
+	}
+	for _, e := range edges {
+		var attrs []string
+		if e.Concurrent {
+			attrs = append(attrs, "style=dashed")
+		}
+		if n := inCycle[e.From]; n != 0 && n == inCycle[e.To] {
+			attrs = append(attrs, "color=red")
+		}
+		line := fmt.Sprintf("\t%s -> %s", dotQuote(e.From), dotQuote(e.To))
+		if len(attrs) > 0 {
+			line += " [" + strings.Join(attrs, ", ") + "]"
+		}
+		b.WriteString(line + ";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type graphNode struct {
+	ID      string `json:"id"`
+	Title   string `json:"title,omitempty"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+func (g *PrereqGraph) writeJSON(w io.Writer, edges []prereqEdge, cycles [][]string) error {
+	missing := g.orphans(edges)
+	out := struct {
+		Nodes  []graphNode  `json:"nodes"`
+		Edges  []prereqEdge `json:"edges"`
+		Cycles [][]string   `json:"cycles"`
+	}{Edges: edges, Cycles: cycles}
+	for id := range g.catalog {
+		out.Nodes = append(out.Nodes, graphNode{ID: id, Title: g.title(id)})
+	}
+	for id := range missing {
+		out.Nodes = append(out.Nodes, graphNode{ID: id, Missing: true})
+	}
+	sort.Slice(out.Nodes, func(i, j int) bool { return out.Nodes[i].ID < out.Nodes[j].ID })
+	if out.Edges == nil {
+		out.Edges = []prereqEdge{}
+	}
+	if out.Cycles == nil {
+		out.Cycles = [][]string{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// runGraphCommand answers -prereqs, -unlocks or -graph.
+func runGraphCommand(ctx context.Context, g *PrereqGraph, id string, prereqs, unlocked bool, format, dest string) error {
+	_, inCatalog := g.catalog[id]
+	if prereqs && !inCatalog {
+		return fmt.Errorf("course %s not found in courses.json", id)
+	}
+
+	out, err := openOutput(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	switch {
+	case prereqs:
+		required, err := g.closure(ctx, id)
+		if err != nil {
+			return err
+		}
+		if format == formatJSON {
+			c, _ := g.cached(id)
+			var missing []string
+			for _, req := range required {
+				if _, ok := g.catalog[req]; !ok {
+					missing = append(missing, req)
+				}
+			}
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(struct {
+				Course        string       `json:"course"`
+				Title         string       `json:"title"`
+				Requirements  *Requirement `json:"requirements"`
+				Prerequisites []string     `json:"prerequisites"`
+				Missing       []string     `json:"missing,omitempty"`
+			}{id, c.Title, c.Requirements, required, missing})
+		} else {
+			g.renderChain(out, id)
+			if len(required) > 0 {
+				fmt.Fprintf(out, "\nAll prerequisites: %s\n", strings.Join(required, ", "))
+			}
+		}
+
+	case unlocked:
+		if err := g.fetchAll(ctx); err != nil {
+			return err
+		}
+		direct, eventually := unlocks(g.edges(), id)
+		if !inCatalog && len(direct) == 0 {
+			return fmt.Errorf("course %s not found in courses.json", id)
+		}
+		if format == formatJSON {
+			if direct == nil {
+				direct = []prereqEdge{}
+			}
+			if eventually == nil {
+				eventually = []string{}
+			}
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(struct {
+				Course     string       `json:"course"`
+				Direct     []prereqEdge `json:"direct"`
+				Eventually []string     `json:"eventually"`
+			}{id, direct, eventually})
+		} else {
+			fmt.Fprintf(out, "%s unlocks:\n", strings.TrimSpace(id+" "+g.title(id)))
+			if len(direct) == 0 {
+				fmt.Fprintln(out, "  nothing")
+			}
+			for _, e := range direct {
+				kind := ""
+				if e.Concurrent {
+					kind = " (can be taken concurrently)"
+				}
+				fmt.Fprintf(out, "  %s %s%s\n", e.To, g.title(e.To), kind)
+			}
+			if len(eventually) > len(direct) {
+				fmt.Fprintf(out, "\nEventually: %s\n", strings.Join(eventually, ", "))
+			}
+		}
+
+	default:
+		if err := g.fetchAll(ctx); err != nil {
+			return err
+		}
+		edges := g.edges()
+		cycles := findCycles(edges)
+		for _, cycle := range cycles {
+			fmt.Fprintf(progress, "Circular requirement: %s\n", strings.Join(cycle, ", "))
+		}
+		missing := g.orphans(edges)
+		var orphans []string
+		for id := range missing {
+			orphans = append(orphans, id)
+		}
+		sort.Strings(orphans)
+		for _, id := range orphans {
+			fmt.Fprintf(progress, "Not in catalog: %s (required by %s)\n", id, strings.Join(missing[id], ", "))
+		}
+
+		switch format {
+		case formatText, formatDOT:
+			err = g.writeDOT(out, edges, cycles)
+		case formatJSON:
+			err = g.writeJSON(out, edges, cycles)
+		default:
+			err = fmt.Errorf("-graph writes dot or json, not %s", format)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindCycles(t *testing.T) {
+	edges := []prereqEdge{
+		{From: "A", To: "B"},
+		{From: "B", To: "C"},
+		{From: "C", To: "A"},
+		{From: "C", To: "D"},
+		{From: "E", To: "E"},
+	}
+	want := [][]string{{"A", "B", "C"}, {"E"}}
+	if got := findCycles(edges); !reflect.DeepEqual(got, want) {
+		t.Errorf("findCycles = %v, want %v", got, want)
+	}
+
+	direct, eventually := unlocks(edges, "B")
+	if len(direct) != 1 || direct[0].To != "C" {
+		t.Errorf("direct unlocks of B = %v", direct)
+	}
+	if want := []string{"A", "C", "D"}; !reflect.DeepEqual(eventually, want) {
+		t.Errorf("eventual unlocks of B = %v, want %v", eventually, want)
+	}
+}
+
+func TestPrereqChain(t *testing.T) {
+	fake := newFakeUVic(t)
+	out := runMain(t, fake, scratchDir(t), "-prereqs", "CSC", "111")
+
+	want := `CSC111 Fundamentals of Programming with Engineering Applications
+Complete all of the following:
+  Earn a minimum grade of C in each of the following:
+    • CSC110 - Fundamentals of Programming I (1.5)
+      Complete all of the following:
+        Earn a minimum grade of C+ in each of the following:
+          • MATH120 - Precalculus Mathematics (1.5) (not in courses.json)
+  Completed or concurrently enrolled in all of:
+    • MATH100 - Calculus I (1.5)
+      Complete all of the following:
+        Complete 1 of the following:
+          Earn a minimum grade of B in each of the following:
+            • Pre-Calculus 12
+          • MATH120 - Precalculus Mathematics (1.5) (not in courses.json)
+        Completed or concurrently enrolled in 1 of:
+          • MATH110 - Matrix Algebra for Engineers (1.5) (not in courses.json)
+          • MATH211 - Matrix Algebra I (1.5) (not in courses.json)
+
+All prerequisites: CSC110, MATH100, MATH110, MATH120, MATH211
+`
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestUnlocksAndGraphExport(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+
+	out := runMain(t, fake, dir, "-unlocks", "MATH", "120")
+	for _, want := range []string{
+		"MATH120 unlocks:",
+		"  CSC110 Fundamentals of Programming I\n",
+		"  MATH100 Calculus I\n",
+		"Eventually: CSC110, CSC111, MATH100",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	fetched := fake.count("/api/v1/catalog/course/" + fakeCatalogID + "/rkgWq1OpQE")
+
+	runMain(t, fake, dir, "-graph", "-o", "graph.dot")
+	if n := fake.count("/api/v1/catalog/course/" + fakeCatalogID + "/rkgWq1OpQE"); n != fetched {
+		t.Errorf("graph refetched a cached course")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "graph.dot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"digraph prerequisites {",
+		`"CSC110" -> "CSC111";`,
+		`"MATH100" -> "CSC111" [style=dashed];`,
+		`"MATH120" [label="MATH120", style=dashed, color=grey];`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("graph missing %q:\n%s", want, data)
+		}
+	}
+}
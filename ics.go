@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// icsTimezone is where every UVic class meets.
+const icsTimezone = "America/Vancouver"
+
+const icsVTimezone = `BEGIN:VTIMEZONE
+TZID:America/Vancouver
+BEGIN:DAYLIGHT
+TZOFFSETFROM:-0800
+TZOFFSETTO:-0700
+TZNAME:PDT
+DTSTART:19700308T020000
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU
+END:DAYLIGHT
+BEGIN:STANDARD
+TZOFFSETFROM:-0700
+TZOFFSETTO:-0800
+TZNAME:PST
+DTSTART:19701101T020000
+RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU
+END:STANDARD
+END:VTIMEZONE`
+
+// icsDays are the RRULE BYDAY codes in meetingDays order.
+var icsDays = [7]string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// icsText escapes a TEXT property value.
+func icsText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// icsLine writes one content line, folded at 75 octets as RFC 5545 asks.
+// Continuation lines start with a space, so they carry at most 74 more.
+func icsLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// Never split a UTF-8 sequence
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// icsEvent is one weekly recurring meeting of a section.
+type icsEvent struct {
+	uid         string
+	summary     string
+	location    string
+	description string
+	start, end  time.Time
+	until       time.Time
+	days        []string
+}
+
+// meetingEvent builds the recurring event for a meeting. Meetings without
+// a time, days or date range (async sections, TBA) have no event.
+func meetingEvent(section CourseSection, meeting MeetingFaculty, loc *time.Location) (icsEvent, bool) {
+	mt := meeting.MeetingTime
+	begin, ok1 := parseClock(mt.BeginTime)
+	end, ok2 := parseClock(mt.EndTime)
+	first, ok3 := parseBannerDate(mt.StartDate)
+	last, ok4 := parseBannerDate(mt.EndDate)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return icsEvent{}, false
+	}
+
+	var days []string
+	meets := meetingDays(mt)
+	for i, day := range meets {
+		if day {
+			days = append(days, icsDays[i])
+		}
+	}
+	if len(days) == 0 {
+		return icsEvent{}, false
+	}
+
+	// The first occurrence is the first meeting day on or after StartDate
+	for !meets[(int(first.Weekday())+6)%7] {
+		first = first.AddDate(0, 0, 1)
+	}
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+
+	location := mt.Building + " " + mt.Room
+	if mt.BuildingDescription != "" {
+		location = html.UnescapeString(mt.BuildingDescription) + " " + mt.Room
+	}
+
+	description := fmt.Sprintf("%s %s: %s\nCRN: %s", section.Subject, section.CourseNumber, section.CourseTitle, section.CourseReferenceNumber)
+	faculty := meeting.Faculty
+	if len(faculty) == 0 {
+		faculty = section.Faculty
+	}
+	if len(faculty) > 0 {
+		description += "\nInstructor: " + getInstructors(faculty)
+	}
+
+	return icsEvent{
+		summary:     fmt.Sprintf("%s %s %s %s", section.Subject, section.CourseNumber, section.Section, section.ScheduleTypeDescription),
+		location:    strings.TrimSpace(location),
+		description: description,
+		start:       day.Add(time.Duration(begin) * time.Minute),
+		end:         day.Add(time.Duration(end) * time.Minute),
+		until:       time.Date(last.Year(), last.Month(), last.Day(), 23, 59, 59, 0, loc),
+		days:        days,
+	}, true
+}
+
+func writeEvent(b *strings.Builder, e icsEvent, stamp time.Time) {
+	const local = "20060102T150405"
+	icsLine(b, "BEGIN:VEVENT")
+	icsLine(b, "UID:"+e.uid)
+	icsLine(b, "DTSTAMP:"+stamp.UTC().Format(local)+"Z")
+	icsLine(b, "DTSTART;TZID="+icsTimezone+":"+e.start.Format(local))
+	icsLine(b, "DTEND;TZID="+icsTimezone+":"+e.end.Format(local))
+	icsLine(b, "RRULE:FREQ=WEEKLY;BYDAY="+strings.Join(e.days, ",")+";UNTIL="+e.until.UTC().Format(local)+"Z")
+	icsLine(b, "SUMMARY:"+icsText(e.summary))
+	if e.location != "" {
+		icsLine(b, "LOCATION:"+icsText(e.location))
+	}
+	icsLine(b, "DESCRIPTION:"+icsText(e.description))
+	icsLine(b, "END:VEVENT")
+}
+
+// exportICS writes a calendar with a weekly recurring event for every
+// meeting of the given sections and returns how many events it wrote.
+func exportICS(ctx context.Context, session *Session, term string, crns []string, w io.Writer) (int, error) {
+	loc, err := time.LoadLocation(icsTimezone)
+	if err != nil {
+		return 0, fmt.Errorf("error loading time zone: %v", err)
+	}
+
+	var events []icsEvent
+	for _, crn := range crns {
+		section, err := session.findSection(ctx, term, crn)
+		if err != nil {
+			return 0, err
+		}
+		details, err := sectionDetails(ctx, session, term, *section)
+		if err != nil {
+			return 0, fmt.Errorf("error fetching meetings for CRN %s: %v", crn, err)
+		}
+		for i, meeting := range details {
+			event, ok := meetingEvent(*section, meeting, loc)
+			if !ok {
+				continue
+			}
+			event.uid = fmt.Sprintf("%s-%s-%d@vikes-scraper", term, crn, i)
+			events = append(events, event)
+		}
+	}
+
+	var b strings.Builder
+	icsLine(&b, "BEGIN:VCALENDAR")
+	icsLine(&b, "VERSION:2.0")
+	icsLine(&b, "PRODID:-//vikes-scraper//UVic timetable//EN")
+	icsLine(&b, "CALSCALE:GREGORIAN")
+	icsLine(&b, "X-WR-CALNAME:"+icsText("UVic "+termName(term)))
+	for _, line := range strings.Split(icsVTimezone, "\n") {
+		icsLine(&b, line)
+	}
+	stamp := time.Now()
+	for _, event := range events {
+		writeEvent(&b, event, stamp)
+	}
+	icsLine(&b, "END:VCALENDAR")
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestICSExportsWeeklyEvents(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	out := runMain(t, fake, dir, "-ics", "20001", "20013")
+	if !strings.Contains(out, "Wrote 2 weekly events to schedule.ics") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "schedule.ics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unfold long lines before matching
+	calendar := strings.ReplaceAll(string(data), "\r\n ", "")
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"TZID:America/Vancouver\r\n",
+		// A01 meets Monday and Thursday from the first day of term
+		"DTSTART;TZID=America/Vancouver:20250106T100000\r\n",
+		"DTEND;TZID=America/Vancouver:20250106T112000\r\n",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,TH;UNTIL=20250405T065959Z\r\n",
+		"SUMMARY:CSC 110 A01 Lecture\r\n",
+		"LOCATION:Engineering & Computer Science Building 123\r\n",
+		`Instructor: Doe\, Jane (jdoe@uvic.ca)`,
+		// B03 only meets on Wednesdays, so starts two days later
+		"DTSTART;TZID=America/Vancouver:20250108T143000\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(calendar, want) {
+			t.Errorf("calendar missing %q:\n%s", want, calendar)
+		}
+	}
+}
+
+func TestICSLineFolding(t *testing.T) {
+	for _, text := range []string{
+		"DESCRIPTION:" + strings.Repeat("é", 60),
+		"DESCRIPTION:" + strings.Repeat("abcdefghij", 20),
+	} {
+		var b strings.Builder
+		icsLine(&b, text)
+		folded := strings.TrimSuffix(b.String(), "\r\n")
+		for _, line := range strings.Split(folded, "\r\n") {
+			if len(line) > 75 {
+				t.Errorf("line is %d octets: %q", len(line), line)
+			}
+		}
+		if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != text {
+			t.Errorf("unfolds to %q, want %q", unfolded, text)
+		}
+	}
+}
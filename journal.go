@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Journal entry kinds. A "finish" entry marks a crawl that completed with
+// nothing left to retry.
+const (
+	journalCourse  = "course"
+	journalSubject = "subject"
+	journalCRN     = "crn"
+	journalFinish  = "finish"
+)
+
+const (
+	journalDone   = "done"
+	journalFailed = "failed"
+)
+
+type journalEntry struct {
+	Term   string         `json:"term"`
+	Kind   string         `json:"kind"`
+	Key    string         `json:"key,omitempty"`
+	Status string         `json:"status,omitempty"`
+	Error  string         `json:"error,omitempty"`
+	Rows   []CSVExportRow `json:"rows,omitempty"`
+	CRNs   []string       `json:"crns,omitempty"`
+}
+
+// crawlJournal is the checkpoint log of one -all crawl, one JSON entry per
+// line. Completed CRNs carry the rows they produced and completed courses
+// and subjects list their CRNs, so a resumed crawl can merge them into its
+// output without fetching them again; failed ones form the set to retry.
+type crawlJournal struct {
+	mu       sync.Mutex
+	file     *os.File
+	term     string
+	state    map[string]journalEntry
+	finished bool
+}
+
+func journalKey(kind, key string) string {
+	return kind + " " + key
+}
+
+// openJournal opens the journal at path for term. A fresh crawl truncates
+// it; resuming refuses a journal with nothing left to do.
+func openJournal(path, term string, resume bool) (*crawlJournal, error) {
+	j := &crawlJournal{term: term, state: make(map[string]journalEntry)}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		if err := j.load(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if len(j.state) == 0 {
+			return nil, fmt.Errorf("nothing to resume: %s has no crawl of %s", path, termName(term))
+		}
+		if j.finished {
+			return nil, fmt.Errorf("nothing to resume: the crawl of %s in %s already finished", termName(term), path)
+		}
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %v", err)
+	}
+	j.file = file
+	return j, nil
+}
+
+func (j *crawlJournal) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A crash can leave a torn final line; everything before it is good
+			continue
+		}
+		if entry.Term != j.term {
+			continue
+		}
+		if entry.Kind == journalFinish {
+			j.finished = true
+			continue
+		}
+		j.state[journalKey(entry.Kind, entry.Key)] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading journal: %v", err)
+	}
+	return nil
+}
+
+func (j *crawlJournal) record(entry journalEntry) error {
+	entry.Term = j.term
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding journal entry: %v", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	if entry.Kind != journalFinish {
+		j.state[journalKey(entry.Kind, entry.Key)] = entry
+	}
+	return nil
+}
+
+// done records a finished CRN with its rows, or a finished course or subject
+// with the CRNs of its rows, which must each have been recorded done.
+func (j *crawlJournal) done(kind, key string, rows []CSVExportRow) error {
+	entry := journalEntry{Kind: kind, Key: key, Status: journalDone}
+	if kind == journalCRN {
+		entry.Rows = rows
+	} else {
+		for _, row := range rows {
+			if row.CRN != "" && !slices.Contains(entry.CRNs, row.CRN) {
+				entry.CRNs = append(entry.CRNs, row.CRN)
+			}
+		}
+	}
+	return j.record(entry)
+}
+
+func (j *crawlJournal) failed(kind, key string, cause error) error {
+	return j.record(journalEntry{Kind: kind, Key: key, Status: journalFailed, Error: cause.Error()})
+}
+
+// finish marks the crawl complete, so it isn't resumed.
+func (j *crawlJournal) finish() error {
+	return j.record(journalEntry{Kind: journalFinish})
+}
+
+// completed returns the rows recorded for a finished course, subject or CRN.
+// A course or subject with no sections has none.
+func (j *crawlJournal) completed(kind, key string) ([]CSVExportRow, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.state[journalKey(kind, key)]
+	if !ok || entry.Status != journalDone {
+		return nil, false
+	}
+	if kind == journalCRN {
+		return entry.Rows, true
+	}
+	var rows []CSVExportRow
+	for _, crn := range entry.CRNs {
+		section, ok := j.state[journalKey(journalCRN, crn)]
+		if !ok || section.Status != journalDone {
+			return nil, false
+		}
+		rows = append(rows, section.Rows...)
+	}
+	return rows, true
+}
+
+// failures lists entries whose latest state is failed, in key order. A nil
+// journal, as in dry runs, has none.
+func (j *crawlJournal) failures() []journalEntry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var failed []journalEntry
+	for _, entry := range j.state {
+		if entry.Status == journalFailed {
+			failed = append(failed, entry)
+		}
+	}
+	sort.Slice(failed, func(a, b int) bool {
+		return journalKey(failed[a].Kind, failed[a].Key) < journalKey(failed[b].Kind, failed[b].Key)
+	})
+	return failed
+}
+
+func (j *crawlJournal) Close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,73 @@
+package main
+
+import "sort"
+
+// LinkGroup is a set of sections of one course that Banner only accepts
+// together, e.g. a lecture and the labs tied to it.
+//
+// Linked sections carry a link identifier, and sections of a course with the
+// same identifier form a group. The identifier is used whole: nothing in
+// Banner's search results says what its characters mean. Sections that
+// aren't linked are collected under the empty key.
+type LinkGroup struct {
+	Key      string
+	Sections []CourseSection
+}
+
+func linkKey(section CourseSection) string {
+	if !section.IsSectionLinked {
+		return ""
+	}
+	return section.LinkIdentifier
+}
+
+func sameCourse(a, b CourseSection) bool {
+	return a.Subject == b.Subject && a.CourseNumber == b.CourseNumber
+}
+
+// sectionsLinkCompatible reports whether a and b may appear in the same
+// registration. Sections of different courses are never restricted; within
+// a course, a linked section only goes with sections of its own link group,
+// so never with an unlinked one.
+func sectionsLinkCompatible(a, b CourseSection) bool {
+	if !sameCourse(a, b) {
+		return true
+	}
+	return linkKey(a) == linkKey(b)
+}
+
+func buildLinkGroups(sections []CourseSection) []LinkGroup {
+	index := make(map[string]int)
+	var groups []LinkGroup
+	for _, section := range sections {
+		key := linkKey(section)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, LinkGroup{Key: key})
+		}
+		groups[i].Sections = append(groups[i].Sections, section)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+	return groups
+}
+
+// linkedPartners returns the sections of other schedule types that must be
+// registered alongside section.
+func linkedPartners(section CourseSection, sections []CourseSection) []CourseSection {
+	key := linkKey(section)
+	if key == "" {
+		return nil
+	}
+	var partners []CourseSection
+	for _, other := range sections {
+		if other.CourseReferenceNumber == section.CourseReferenceNumber || !sameCourse(section, other) {
+			continue
+		}
+		if linkKey(other) == key && other.ScheduleTypeDescription != section.ScheduleTypeDescription {
+			partners = append(partners, other)
+		}
+	}
+	return partners
+}
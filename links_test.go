@@ -0,0 +1,121 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func linked(subject, number, crn, scheduleType, link string) CourseSection {
+	return CourseSection{
+		Subject:                 subject,
+		CourseNumber:            number,
+		CourseReferenceNumber:   crn,
+		ScheduleTypeDescription: scheduleType,
+		LinkIdentifier:          link,
+		IsSectionLinked:         link != "",
+	}
+}
+
+func TestLinkKey(t *testing.T) {
+	tests := []struct {
+		section CourseSection
+		want    string
+	}{
+		{linked("CSC", "110", "1", "Lecture", "A1"), "A1"},
+		{linked("CSC", "110", "2", "Lab", "B12"), "B12"},
+		{linked("CSC", "110", "4", "Lecture", ""), ""},
+		// Banner sometimes leaves an identifier on sections it doesn't link
+		{CourseSection{LinkIdentifier: "A1"}, ""},
+	}
+	for _, tt := range tests {
+		if got := linkKey(tt.section); got != tt.want {
+			t.Errorf("linkKey(%q, linked %v) = %q, want %q", tt.section.LinkIdentifier, tt.section.IsSectionLinked, got, tt.want)
+		}
+	}
+}
+
+func TestSectionsLinkCompatible(t *testing.T) {
+	lectureA1 := linked("CSC", "110", "1", "Lecture", "L1")
+	lectureA2 := linked("CSC", "110", "2", "Lecture", "L2")
+	labB1 := linked("CSC", "110", "3", "Lab", "L1")
+	unlinked := linked("CSC", "110", "4", "Tutorial", "")
+	otherCourse := linked("MATH", "100", "5", "Lab", "L2")
+
+	tests := []struct {
+		name string
+		a, b CourseSection
+		want bool
+	}{
+		{"same group", lectureA1, labB1, true},
+		{"different groups", lectureA2, labB1, false},
+		{"linked and unlinked", lectureA2, unlinked, false},
+		{"both unlinked", unlinked, linked("CSC", "110", "6", "Lab", ""), true},
+		{"other course", lectureA1, otherCourse, true},
+	}
+	for _, tt := range tests {
+		if got := sectionsLinkCompatible(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: sectionsLinkCompatible = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := sectionsLinkCompatible(tt.b, tt.a); got != tt.want {
+			t.Errorf("%s reversed: sectionsLinkCompatible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildLinkGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		sections []CourseSection
+		want     []string // key=CRNs of each group, in order
+	}{
+		{"none", nil, nil},
+		{"unlinked", []CourseSection{
+			linked("MATH", "100", "1", "Lecture", ""),
+			linked("MATH", "100", "2", "Tutorial", ""),
+		}, []string{"=1,2"}},
+		{"lectures and labs", []CourseSection{
+			linked("CSC", "110", "1", "Lecture", "L2"),
+			linked("CSC", "110", "2", "Lecture", "L1"),
+			linked("CSC", "110", "3", "Lab", "L1"),
+			linked("CSC", "110", "4", "Lab", "L2"),
+			linked("CSC", "110", "5", "Tutorial", ""),
+			linked("CSC", "110", "6", "Lab", "L1"),
+			// Different identifiers are different groups, whatever they look like
+			linked("CSC", "110", "7", "Lab", "B1"),
+		}, []string{"=5", "B1=7", "L1=2,3,6", "L2=1,4"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, group := range buildLinkGroups(tt.sections) {
+			var crns []string
+			for _, section := range group.Sections {
+				crns = append(crns, section.CourseReferenceNumber)
+			}
+			got = append(got, group.Key+"="+strings.Join(crns, ","))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: groups %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExportsCarryLinkGroups(t *testing.T) {
+	want := map[string]string{"20001": "L1", "20002": "L2", "20011": "L1", "20012": "L1", "20013": "L2", "21001": "", "21011": ""}
+
+	for _, format := range []string{"csv", "json"} {
+		name := "courses." + format
+		fake := newFakeUVic(t)
+		dir := scratchDir(t)
+		runMain(t, fake, dir, "-all", "-format="+format, "-o", name)
+		rows, err := readExportFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			if row.CRN != "" && row.LinkGroup != want[row.CRN] {
+				t.Errorf("%s: CRN %s has link group %q, want %q", name, row.CRN, row.LinkGroup, want[row.CRN])
+			}
+		}
+	}
+}
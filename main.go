@@ -1,15 +1,16 @@
 package main
 
 import (
-	"encoding/csv"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -40,9 +41,17 @@ type CSVExportRow struct {
 	CreditHours         string
 	StartDate           string
 	EndDate             string
+	Enrollment          int
+	MaximumEnrollment   int
+	SeatsAvailable      int
+	WaitCount           int
+	WaitCapacity        int
+	LinkGroup           string // sections of a course sharing one must be registered together
+	Faculty             []Faculty
 }
 
 type CourseOutput struct {
+	Term            string `json:"term"`
 	CRN             string `json:"crn"`
 	Subject         string `json:"subject"`
 	CourseNumber    string `json:"course_number"`
@@ -51,12 +60,23 @@ type CourseOutput struct {
 	Professor       string `json:"professor"`
 	Email           string `json:"email"`
 	Schedule        string `json:"schedule"`
+	ScheduleType    string `json:"schedule_type"`
 	Location        string `json:"location"`
+	Building        string `json:"building"`
+	Room            string `json:"room"`
+	Campus          string `json:"campus"`
 	Days            string `json:"days"`
 	Enrollment      string `json:"enrollment"`
+	SeatsAvailable  int    `json:"seats_available"`
+	WaitCount       int    `json:"wait_count"`
+	WaitCapacity    int    `json:"wait_capacity"`
+	LinkGroup       string `json:"link_group,omitempty"`
 	CreditHours     string `json:"credit_hours"`
 	InstructionType string `json:"instruction_type"`
 	DateRange       string `json:"date_range"`
+	StartDate       string `json:"start_date"`
+	EndDate         string `json:"end_date"`
+	Available       bool   `json:"available"`
 }
 
 func formatTime(time string) string {
@@ -84,6 +104,12 @@ func getDays(mt MeetingTime) string {
 	if mt.Friday {
 		days += "F"
 	}
+	if mt.Saturday {
+		days += "S"
+	}
+	if mt.Sunday {
+		days += "U"
+	}
 	return days
 }
 
@@ -99,6 +125,16 @@ func getInstructors(faculty []Faculty) string {
 	return strings.Join(names, ", ")
 }
 
+func getInstructorEmails(faculty []Faculty) string {
+	var emails []string
+	for _, f := range faculty {
+		if f.EmailAddress != "" {
+			emails = append(emails, f.EmailAddress)
+		}
+	}
+	return strings.Join(emails, ", ")
+}
+
 func loadCoursesFromJSON(filename string) ([]Course, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -118,65 +154,6 @@ func loadCoursesFromJSON(filename string) ([]Course, error) {
 	return courses, nil
 }
 
-func exportToCSV(rows []CSVExportRow, filename string) error {
-	out, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("error creating file: %v", err)
-	}
-	defer out.Close()
-
-	writer := csv.NewWriter(out)
-	defer writer.Flush()
-
-	header := []string{
-		"Term", "Subject", "Course Name", "Course Number", "CRN", "Section",
-		"Time", "Days", "Location", "Date Range", "Schedule Type",
-		"Instructor", "Instructional Method", "Units", "Available",
-		"Campus", "Campus Description", "Building Code", "Building Name", "Room Number",
-		"Meeting Type", "Meeting Description", "Instructor Email", "Credit Hours",
-		"Start Date", "End Date",
-	}
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("error writing header: %v", err)
-	}
-
-	for _, row := range rows {
-		record := []string{
-			row.Term,
-			row.Subject,
-			row.CourseName,
-			row.CourseNumber,
-			row.CRN,
-			row.Section,
-			row.Time,
-			row.Days,
-			row.Location,
-			row.DateRange,
-			row.ScheduleType,
-			row.Instructor,
-			row.InstructionalMethod,
-			row.Units,
-			fmt.Sprintf("%v", row.Available),
-			row.Campus,
-			row.CampusDescription,
-			row.BuildingCode,
-			row.BuildingName,
-			row.RoomNumber,
-			row.MeetingType,
-			row.MeetingDescription,
-			row.InstructorEmail,
-			row.CreditHours,
-			row.StartDate,
-			row.EndDate,
-		}
-		if err := writer.Write(record); err != nil {
-			return fmt.Errorf("error writing record: %v", err)
-		}
-	}
-
-	return nil
-}
-
 func findCourseInJSON(subject, number string) (string, error) {
 	// Read and parse courses.json
 	data, err := os.ReadFile("courses.json")
@@ -206,7 +183,7 @@ func findCourseInJSON(subject, number string) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("course %s %s not found", subject, number)
+	return "", fmt.Errorf("course %s %s not found in courses.json (run -catalog-sync if it is new)", subject, number)
 }
 
 func main() {
@@ -214,10 +191,615 @@ func main() {
 	coursesFlag := flag.Bool("courses", false, "fetch multiple courses info")
 	allCoursesFlag := flag.Bool("all", false, "fetch all courses and export to CSV")
 	dryRunFlag := flag.Bool("dry-run", false, "dry run")
-	semesterFlag := flag.String("semester", "202501", "term code (e.g., 202501, 202509)")
+	semesterFlag := flag.String("semester", "current", "term code (e.g., 202501, 202509) or alias (current, next, latest, fall2025)")
+	termsFlag := flag.Bool("terms", false, "list the terms Banner offers")
+	flag.StringVar(&termsCachePath, "terms-cache", termsCachePath, "where the Banner term list is cached")
+	scheduleFlag := flag.Bool("schedule", false, "generate conflict-free schedules for the given courses")
+	maxSchedulesFlag := flag.Int("max-schedules", 5, "number of schedules to display with -schedule")
+	icsFlag := flag.Bool("ics", false, "write an iCalendar file of the sections whose CRNs are given as arguments")
+	crawlFlag := flag.String("crawl", crawlPerCourse, "how -all queries Banner: course, subject or term")
+	resumeFlag := flag.Bool("resume", false, "resume an interrupted -all crawl from its journal")
+	journalFlag := flag.String("journal", "crawl.journal", "checkpoint journal written by -all")
+	flag.StringVar(&bannerBaseURL, "banner-url", bannerBaseURL, "base URL of the Banner registration API")
+	flag.StringVar(&kualiBaseURL, "kuali-url", kualiBaseURL, "base URL of the Kuali catalog API")
+	flag.StringVar(&kualiCatalogID, "kuali-catalog", kualiCatalogID, "Kuali catalog id, or current for the published catalog")
+	requirementsFlag := flag.Bool("requirements", false, "show the parsed prerequisite rule of a course (text or -format=json)")
+	prereqsFlag := flag.Bool("prereqs", false, "show the full prerequisite chain of a course")
+	unlocksFlag := flag.Bool("unlocks", false, "list the courses a course unlocks")
+	graphFlag := flag.Bool("graph", false, "export the catalog's prerequisite graph (-format=dot or json)")
+	prereqCacheFlag := flag.String("prereq-cache", "prereqs.json", "cache of prerequisite rules fetched from Kuali")
+	eligibleFlag := flag.Bool("eligible", false, "list the catalog courses a student can take, given -transcript")
+	transcriptFlag := flag.String("transcript", "transcript.txt", "completed courses, one per line with optional grade and term")
+	offeredFlag := flag.Bool("offered", false, "with -eligible, only list courses offered in -semester")
+	searchFlag := flag.Bool("search", false, "search courses.json offline, e.g. -search graphics level:300 subject:CSC")
+	limitFlag := flag.Int("limit", 20, "maximum number of -search results, or 0 for all")
+	snapshotDirFlag := flag.String("snapshot-dir", "snapshots", "directory where every complete -all crawl is kept")
+	noSnapshotFlag := flag.Bool("no-snapshot", false, "don't keep a snapshot of this -all crawl")
+	snapshotsFlag := flag.Bool("snapshots", false, "list the stored -all snapshots")
+	snapshotFlag := flag.String("snapshot", "", "with -all, export this stored snapshot (id, hash prefix or latest) instead of crawling")
+	pruneSnapshotsFlag := flag.String("prune-snapshots", "", "delete snapshots older than this age (e.g. 30d), keeping each term's newest")
+	sectionHistoryFlag := flag.Bool("section-history", false, "show when a CRN appeared in or disappeared from the -semester snapshots")
+	diffFlag := flag.Bool("diff", false, "compare two crawls, each an export file or a -semester snapshot")
+	watchFlag := flag.Bool("watch", false, "poll the given CRNs and report when seats or waitlist spots open or fill")
+	intervalFlag := flag.Duration("interval", time.Minute, "time between -watch or -collect polls")
+	pollsFlag := flag.Int("polls", 0, "stop -watch or -collect after this many polls (0 runs until interrupted)")
+	webhookFlag := flag.String("webhook", "", "with -watch, POST each event as JSON to this URL")
+	smtpFlag := flag.String("smtp", "", "with -watch, email each event through this SMTP server (host:port)")
+	smtpFromFlag := flag.String("smtp-from", "", "sender address of -watch emails")
+	smtpToFlag := flag.String("smtp-to", "", "comma-separated recipients of -watch emails")
+	collectFlag := flag.Bool("collect", false, "record the -semester's enrollment and waitlist numbers every -interval, optionally for some subjects")
+	enrollmentReportFlag := flag.Bool("enrollment-report", false, "show fill curves, fill dates and peak waitlists recorded by -collect")
+	enrollmentDBFlag := flag.String("enrollment-db", "enrollment.db", "where -collect records enrollment")
+	serveFlag := flag.Bool("serve", false, "serve crawled terms, courses and sections as a JSON API")
+	addrFlag := flag.String("addr", "localhost:8080", "address -serve listens on")
+	refreshFlag := flag.Bool("refresh", false, "with -serve, fetch what the snapshots and prerequisite cache are missing from Banner and Kuali")
+	refreshTTLFlag := flag.Duration("refresh-ttl", 15*time.Minute, "with -serve -refresh, how long fetched sections are kept before Banner is asked again")
+	catalogSyncFlag := flag.Bool("catalog-sync", false, "regenerate courses.json from the current Kuali catalog")
+	schedulerConfig := DefaultSchedulerConfig()
+	timeoutFlag := flag.Duration("timeout", schedulerConfig.Timeout, "timeout for each Banner or Kuali request")
+	flag.Float64Var(&schedulerConfig.Rate, "rate", schedulerConfig.Rate, "maximum requests per second to each host")
+	flag.IntVar(&schedulerConfig.Burst, "burst", schedulerConfig.Burst, "requests allowed in a burst before -rate applies")
+	flag.IntVar(&schedulerConfig.HostConcurrency, "host-concurrency", schedulerConfig.HostConcurrency, "maximum concurrent requests to each host")
+	flag.IntVar(&schedulerConfig.MaxRetries, "max-retries", schedulerConfig.MaxRetries, "retries for throttled or failed requests")
+	formatFlag := flag.String("format", "", "output format: text, json, ndjson, csv, markdown or sqlite (default text, or csv for -all)")
+	outputFlag := flag.String("o", "", "write results to this file instead of stdout (-all defaults to courses.csv or courses.db)")
+	recordFlag := flag.String("record", "", "record every HTTP exchange to this directory")
+	replayFlag := flag.String("replay", "", "serve HTTP responses from a directory made by -record instead of the network")
 	flag.Parse()
 
-	if *courseFlag || *coursesFlag {
+	switch *crawlFlag {
+	case crawlPerCourse, crawlPerSubject, crawlWholeTerm:
+	default:
+		fmt.Printf("Unknown -crawl mode %q (want course, subject or term)\n", *crawlFlag)
+		return
+	}
+	format := *formatFlag
+	if format == "" {
+		format = formatText
+		if *allCoursesFlag {
+			format = formatCSV
+		}
+	}
+	if err := checkFormat(format); err != nil {
+		fmt.Println(err)
+		return
+	}
+	output := *outputFlag
+	if output == "" && *allCoursesFlag {
+		switch format {
+		case formatCSV:
+			output = "courses.csv"
+		case formatSQLite:
+			output = "courses.db"
+		}
+	}
+	progress = os.Stdout
+	if isStdout(output) && format != formatText {
+		progress = os.Stderr
+	}
+
+	if *dryRunFlag && *resumeFlag {
+		fmt.Println("-dry-run and -resume cannot be used together")
+		return
+	}
+
+	schedulerConfig.Timeout = *timeoutFlag
+	sharedTransport = NewScheduler(http.DefaultTransport, schedulerConfig)
+	switch {
+	case *recordFlag != "" && *replayFlag != "":
+		fmt.Println("-record and -replay cannot be used together")
+		return
+	case *recordFlag != "":
+		// Record what callers finally see, after the scheduler's retries
+		recorder, err := NewRecorder(*recordFlag, sharedTransport)
+		if err != nil {
+			fmt.Printf("Error starting recording: %v\n", err)
+			return
+		}
+		sharedTransport = recorder
+	case *replayFlag != "":
+		replayer, err := NewReplayer(*replayFlag)
+		if err != nil {
+			fmt.Printf("Error starting replay: %v\n", err)
+			return
+		}
+		sharedTransport = replayer
+	}
+
+	if *catalogSyncFlag {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if output == "" {
+			output = "courses.json"
+		}
+		changes, err := syncCatalog(ctx, output)
+		if err != nil {
+			fmt.Printf("Error syncing catalog: %v\n", err)
+			return
+		}
+		changes.print(os.Stdout)
+		fmt.Printf("Wrote catalog %s to %s\n", kualiCatalogID, output)
+		return
+	}
+
+	store := snapshotStore{dir: *snapshotDirFlag}
+	if *snapshotsFlag {
+		terms, err := store.terms()
+		if err == nil {
+			err = store.printSnapshots(os.Stdout, terms)
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	if *pruneSnapshotsFlag != "" {
+		age, err := parseAge(*pruneSnapshotsFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		removed, err := store.prune(time.Now().Add(-age))
+		for _, info := range removed {
+			fmt.Printf("Removed %s snapshot %s\n", info.Term, info.ID)
+		}
+		if err != nil {
+			fmt.Printf("Error pruning snapshots: %v\n", err)
+			return
+		}
+		fmt.Printf("Pruned %d snapshots\n", len(removed))
+		return
+	}
+
+	if *searchFlag {
+		query := strings.Join(flag.Args(), " ")
+		if strings.TrimSpace(query) == "" {
+			fmt.Println("Usage: -search [-limit N] WORDS [subject:SUBJ] [level:N00]")
+			return
+		}
+		if isStdout(output) && format != formatText {
+			progress = os.Stderr
+		}
+		idx, err := loadSearchIndex("courses.json", *prereqCacheFlag)
+		if err != nil {
+			fmt.Printf("Error loading courses: %v\n", err)
+			return
+		}
+		results, err := idx.search(query, *limitFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		out, err := openOutput(output)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer out.Close()
+		if err := writeSearchResults(out, results, format); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := out.Close(); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	if *requirementsFlag {
+		args := flag.Args()
+		if len(args) != 2 {
+			fmt.Println("Usage: -requirements SUBJECT NUMBER")
+			return
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		pid, err := findCourseInJSON(args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error finding course: %v\n", err)
+			return
+		}
+		kualiInfo, err := fetchKualiCourseInfo(ctx, pid)
+		if err != nil {
+			fmt.Printf("Error fetching Kuali info: %v\n", err)
+			return
+		}
+		requirements, err := parsePrerequisites(kualiInfo.PreOrCorequisites)
+		if err != nil {
+			fmt.Printf("Error reading prerequisites: %v\n", err)
+			return
+		}
+
+		if format == formatJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(struct {
+				Course       string       `json:"course"`
+				Title        string       `json:"title"`
+				Requirements *Requirement `json:"requirements"`
+			}{kualiInfo.CatalogCourseId, kualiInfo.Title, requirements})
+			return
+		}
+		fmt.Printf("%s %s: %s\n", args[0], args[1], kualiInfo.Title)
+		if requirements == nil {
+			fmt.Println("No prerequisites")
+			return
+		}
+		renderRequirement(os.Stdout, requirements, 0)
+		return
+	}
+
+	if *prereqsFlag || *unlocksFlag || *graphFlag {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		var id string
+		if !*graphFlag {
+			args := flag.Args()
+			if len(args) != 2 {
+				fmt.Println("Usage: -prereqs|-unlocks SUBJECT NUMBER")
+				return
+			}
+			id = strings.ToUpper(args[0] + args[1])
+		}
+		if isStdout(output) && (*graphFlag || format != formatText) {
+			progress = os.Stderr
+		}
+
+		courses, err := loadCoursesFromJSON("courses.json")
+		if err != nil {
+			fmt.Printf("Error loading courses: %v\n", err)
+			return
+		}
+		graph, err := loadPrereqGraph(ctx, courses, *prereqCacheFlag)
+		if err != nil {
+			fmt.Printf("Error loading prerequisites: %v\n", err)
+			return
+		}
+		if err := runGraphCommand(ctx, graph, id, *prereqsFlag, *unlocksFlag, format, output); err != nil {
+			fmt.Fprintf(progress, "Error: %v\n", err)
+		}
+		return
+	}
+
+	if *termsFlag {
+		terms, err := loadTerms(context.Background(), true)
+		if err != nil {
+			fmt.Printf("Error fetching terms: %v\n", err)
+			return
+		}
+		printTerms(os.Stdout, terms, time.Now())
+		return
+	}
+
+	if *diffFlag {
+		args := flag.Args()
+		if len(args) != 2 {
+			fmt.Println("Usage: -diff OLD NEW (export files, or snapshot ids of -semester)")
+			return
+		}
+		if isStdout(output) && format != formatText {
+			progress = os.Stderr
+		}
+
+		resolved := false
+		var crawls [2][]CSVExportRow
+		for i, arg := range args {
+			rows, err := loadCrawl(store, arg, semesterFlag, &resolved)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			crawls[i] = rows
+		}
+		out, err := openOutput(output)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer out.Close()
+		if err := writeDiff(out, diffCrawls(crawls[0], crawls[1]), format); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := out.Close(); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	// Commands that only read stored crawls or enrollment resolve the term
+	// without Banner, so they keep working offline
+	offline := *sectionHistoryFlag || *enrollmentReportFlag || (*serveFlag && !*refreshFlag) || (*allCoursesFlag && *snapshotFlag != "")
+	if offline || *icsFlag || *courseFlag || *coursesFlag || *scheduleFlag || *allCoursesFlag || *watchFlag || *collectFlag || *serveFlag || (*eligibleFlag && *offeredFlag) {
+		var term string
+		var err error
+		if offline {
+			var stored []string
+			if *enrollmentReportFlag {
+				stored, err = enrollmentTerms(*enrollmentDBFlag)
+			} else {
+				stored, err = store.terms()
+			}
+			if err == nil {
+				term, err = localTerm(*semesterFlag, stored)
+			}
+		} else {
+			term, err = checkTerm(context.Background(), *semesterFlag)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if term != *semesterFlag {
+			fmt.Fprintf(progress, "Using term %s (%s)\n", term, termName(term))
+		}
+		*semesterFlag = term
+	}
+
+	if *icsFlag {
+		crns := flag.Args()
+		if len(crns) == 0 {
+			fmt.Println("Usage: -ics [-o FILE] CRN1 [CRN2 ...]")
+			return
+		}
+		if output == "" {
+			output = "schedule.ics"
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		session, err := NewSession()
+		if err != nil {
+			fmt.Println("Error creating session:", err)
+			return
+		}
+		out, err := openOutput(output)
+		if err != nil {
+			fmt.Printf("Error writing calendar: %v\n", err)
+			return
+		}
+		defer out.Close()
+
+		events, err := exportICS(ctx, session, *semesterFlag, crns, out)
+		if err != nil {
+			fmt.Printf("Error writing calendar: %v\n", err)
+			return
+		}
+		if err := out.Close(); err != nil {
+			fmt.Printf("Error writing calendar: %v\n", err)
+			return
+		}
+		if !isStdout(output) {
+			fmt.Printf("Wrote %d weekly events to %s\n", events, output)
+		}
+		return
+	}
+
+	if *watchFlag {
+		crns := flag.Args()
+		if len(crns) == 0 {
+			fmt.Println("Usage: -watch [-interval 1m] [-webhook URL] [-smtp HOST:PORT -smtp-from ADDR -smtp-to ADDRS] CRN1 [CRN2 ...]")
+			return
+		}
+		if *intervalFlag < minWatchInterval {
+			fmt.Printf("-interval must be at least %v\n", minWatchInterval)
+			return
+		}
+		jsonEvents := format == formatJSON || format == formatNDJSON
+		if !jsonEvents && format != formatText {
+			fmt.Printf("-watch writes text or json events, not %s\n", format)
+			return
+		}
+		if jsonEvents {
+			progress = os.Stderr
+		}
+
+		notifiers := []Notifier{streamNotifier{w: os.Stdout, jsonMode: jsonEvents}}
+		if *webhookFlag != "" {
+			notifiers = append(notifiers, webhookNotifier{url: *webhookFlag, client: &http.Client{Timeout: *timeoutFlag}})
+		}
+		if *smtpFlag != "" {
+			notifier, err := newSMTPNotifier(*smtpFlag, *smtpFromFlag, *smtpToFlag)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			notifiers = append(notifiers, notifier)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		session, err := NewSession()
+		if err != nil {
+			fmt.Println("Error creating session:", err)
+			return
+		}
+		w := &watcher{term: *semesterFlag, fetch: session.findSection, notifiers: notifiers, last: make(map[string]CourseSection)}
+		w.run(ctx, crns, *intervalFlag, *pollsFlag)
+		return
+	}
+
+	if *serveFlag {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		courses, err := loadCoursesFromJSON("courses.json")
+		if err != nil {
+			fmt.Printf("Error loading courses: %v\n", err)
+			return
+		}
+		api := newAPIServer(store, *semesterFlag, courses)
+		if *refreshFlag {
+			graph, err := loadPrereqGraph(ctx, courses, *prereqCacheFlag)
+			if err != nil {
+				fmt.Printf("Error loading prerequisites: %v\n", err)
+				return
+			}
+			api.details = func(ctx context.Context, id string) (graphCourse, bool, error) {
+				c, ok, err := graph.fetch(ctx, id)
+				if err != nil {
+					return c, ok, err
+				}
+				if err := graph.save(); err != nil {
+					fmt.Fprintf(progress, "Warning: %v\n", err)
+				}
+				return c, ok, nil
+			}
+			api.refresh = true
+			api.ttl = *refreshTTLFlag
+		} else if cache, err := readPrereqCache(*prereqCacheFlag); err == nil {
+			api.details = func(ctx context.Context, id string) (graphCourse, bool, error) {
+				c, ok := cache.Courses[id]
+				return c, ok, nil
+			}
+		} else if !os.IsNotExist(err) {
+			fmt.Fprintf(progress, "Warning: ignoring prerequisite cache: %v\n", err)
+		}
+
+		server := &http.Server{Addr: *addrFlag, Handler: api.routes()}
+		go func() {
+			<-ctx.Done()
+			server.Shutdown(context.Background())
+		}()
+		fmt.Printf("Serving on http://%s (default term %s)\n", *addrFlag, termName(*semesterFlag))
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	if *collectFlag {
+		if *intervalFlag < minWatchInterval {
+			fmt.Printf("-interval must be at least %v\n", minWatchInterval)
+			return
+		}
+		db, err := openEnrollmentDB(*enrollmentDBFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer db.Close()
+		recorder, err := newEnrollmentRecorder(db, *semesterFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		session, err := NewSession()
+		if err != nil {
+			fmt.Println("Error creating session:", err)
+			return
+		}
+		collectEnrollment(ctx, session, recorder, collectSubjects(flag.Args()), *intervalFlag, *pollsFlag)
+		return
+	}
+
+	if *enrollmentReportFlag {
+		args := flag.Args()
+		if len(args) > 2 {
+			fmt.Println("Usage: -enrollment-report [-semester TERM] [SUBJECT [COURSE#]]")
+			return
+		}
+		var subject, number string
+		if len(args) > 0 {
+			subject = strings.ToUpper(args[0])
+		}
+		if len(args) > 1 {
+			number = args[1]
+		}
+		if isStdout(output) && format != formatText {
+			progress = os.Stderr
+		}
+
+		if _, err := os.Stat(*enrollmentDBFlag); err != nil {
+			fmt.Printf("No enrollment recorded in %s (see -collect)\n", *enrollmentDBFlag)
+			return
+		}
+		db, err := openEnrollmentDB(*enrollmentDBFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer db.Close()
+		reports, err := enrollmentReports(db, *semesterFlag, subject, number)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		out, err := openOutput(output)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer out.Close()
+		if err := writeEnrollmentReport(out, reports, format); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := out.Close(); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	if *sectionHistoryFlag {
+		if len(flag.Args()) != 1 {
+			fmt.Println("Usage: -section-history [-semester TERM] CRN")
+			return
+		}
+		crn := flag.Arg(0)
+		events, err := store.sectionHistory(*semesterFlag, crn)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		printSectionHistory(os.Stdout, crn, events)
+		return
+	}
+
+	if *eligibleFlag {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if isStdout(output) && format != formatText {
+			progress = os.Stderr
+		}
+
+		transcript, err := loadTranscript(*transcriptFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		courses, err := loadCoursesFromJSON("courses.json")
+		if err != nil {
+			fmt.Printf("Error loading courses: %v\n", err)
+			return
+		}
+		graph, err := loadPrereqGraph(ctx, courses, *prereqCacheFlag)
+		if err != nil {
+			fmt.Printf("Error loading prerequisites: %v\n", err)
+			return
+		}
+		term := ""
+		if *offeredFlag {
+			term = *semesterFlag
+		}
+		if err := runEligibility(ctx, graph, transcript, term, format, output); err != nil {
+			fmt.Fprintf(progress, "Error: %v\n", err)
+		}
+		return
+	}
+
+	if *courseFlag || *coursesFlag || *scheduleFlag {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
 		session, err := NewSession()
 
 		if err != nil {
@@ -236,7 +818,6 @@ func main() {
 			for i := 0; i < len(args); i += 2 {
 				courseQueries = append(courseQueries, []string{args[i], args[i+1]})
 			}
-			fmt.Printf("DEBUG: Processing %d course queries with -courses flag\n", len(courseQueries))
 		} else {
 			// Single course mode
 			args := flag.Args()
@@ -246,7 +827,6 @@ func main() {
 				for i := 0; i < len(args); i += 2 {
 					courseQueries = append(courseQueries, []string{args[i], args[i+1]})
 				}
-				fmt.Printf("DEBUG: Processing %d courses without -courses flag\n", len(courseQueries))
 			} else {
 				// Standard single course processing
 				courseSubject, courseID, err := getCourseDetails(os.Stdin, args...)
@@ -259,6 +839,28 @@ func main() {
 		}
 
 		// If we're generating schedules, handle that separately
+		if *scheduleFlag {
+			if err := generateSchedules(ctx, session, *semesterFlag, courseQueries, *maxSchedulesFlag); err != nil {
+				fmt.Printf("Error generating schedules: %v\n", err)
+			}
+			return
+		}
+
+		if format != formatText || !isStdout(output) {
+			var rows []CSVExportRow
+			for _, query := range courseQueries {
+				courseRows, err := fetchCourseRows(ctx, session, *semesterFlag, query[0], query[1])
+				if err != nil {
+					fmt.Fprintf(progress, "Error fetching Banner course info: %v\n", err)
+					return
+				}
+				rows = append(rows, courseRows...)
+			}
+			if err := writeRows(rows, format, output); err != nil {
+				fmt.Fprintf(progress, "Error writing output: %v\n", err)
+			}
+			return
+		}
 
 		for _, query := range courseQueries {
 			courseSubject, courseID := query[0], query[1]
@@ -267,7 +869,7 @@ func main() {
 				fmt.Printf("Error finding course: %v\n", err)
 				return
 			}
-			kualiInfo, err := fetchKualiCourseInfo(pid)
+			kualiInfo, err := fetchKualiCourseInfo(ctx, pid)
 			if err != nil {
 				fmt.Printf("Error fetching Kuali info: %v\n", err)
 				return
@@ -285,50 +887,12 @@ func main() {
 			fmt.Printf("Credits: %s\n", kualiInfo.Credits.Value)
 			fmt.Printf("Hours: %s\n", kualiInfo.HoursCatalogText)
 
-			if kualiInfo.PreOrCorequisites != "" {
-				prereq := strings.ReplaceAll(kualiInfo.PreOrCorequisites, "<div>", "")
-				prereq = strings.ReplaceAll(prereq, "</div>", "")
-				prereq = strings.ReplaceAll(prereq, "<ul>", "")
-				prereq = strings.ReplaceAll(prereq, "</ul>", "")
-				prereq = strings.ReplaceAll(prereq, "<li>", "")
-				prereq = strings.ReplaceAll(prereq, "</li>", "\n")
-				prereq = strings.ReplaceAll(prereq, "<span>", "")
-				prereq = strings.ReplaceAll(prereq, "</span>", "")
-				prereq = strings.ReplaceAll(prereq, "<a href=\"#/courses/view/[a-zA-Z0-9]+\" target=\"_blank\">", "")
-				prereq = strings.ReplaceAll(prereq, "</a>", "")
-				prereq = strings.ReplaceAll(prereq, "<!-- -->", "")
-				prereq = strings.ReplaceAll(prereq, " style=\"margin-left:5px\"", "")
-				prereq = strings.ReplaceAll(prereq, " style=\"margin-top:5px;margin-bottom:5px\"", "")
-				prereq = strings.ReplaceAll(prereq, " data-test=\"ruleView-[A-Z]\"", "")
-				prereq = strings.ReplaceAll(prereq, " data-test=\"ruleView-[A-Z]-result\"", "")
-
-				// Clean up any remaining HTML tags
-				re := regexp.MustCompile("<[^>]*>")
-				prereq = re.ReplaceAllString(prereq, "")
-
-				// Clean up multiple newlines and spaces
-				prereq = strings.ReplaceAll(prereq, "\n\n\n", "\n")
-				prereq = strings.ReplaceAll(prereq, "  ", " ")
-
-				// Format prerequisites nicely
-				prereq = strings.ReplaceAll(prereq, "Complete 1 of the following", "Complete 1 of the following:")
-				prereq = strings.ReplaceAll(prereq, "Completed or concurrently enrolled in 1 of:", "\nCompleted or concurrently enrolled in 1 of:")
-				prereq = strings.ReplaceAll(prereq, " - ", ": ")
-
-				// Add bullet points for courses
-				lines := strings.Split(prereq, "\n")
-				var formattedLines []string
-				for _, line := range lines {
-					if strings.Contains(line, "BIOL") || strings.Contains(line, "MICR") {
-						formattedLines = append(formattedLines, "• "+line)
-					} else {
-						formattedLines = append(formattedLines, line)
-					}
-				}
-				prereq = strings.Join(formattedLines, "\n")
-
+			requirements, err := parsePrerequisites(kualiInfo.PreOrCorequisites)
+			if err != nil {
+				fmt.Printf("Error reading prerequisites: %v\n", err)
+			} else if requirements != nil {
 				fmt.Printf("\nPrerequisites:\n%s\n", strings.Repeat("-", 13))
-				fmt.Printf("%s\n", prereq)
+				renderRequirement(os.Stdout, requirements, 0)
 			}
 
 			if kualiInfo.SupplementalNotes != "" {
@@ -354,26 +918,27 @@ func main() {
 				fmt.Printf("\nNotes:\n%s\n", strings.Repeat("-", 6))
 				fmt.Printf("%s\n", notes)
 			}
-			bannerResponse, err := session.fetchCourseInfo(*semesterFlag, courseSubject, courseID)
+			bannerResponse, err := session.fetchCourseInfo(ctx, *semesterFlag, courseSubject, courseID)
 			if err != nil {
 				fmt.Printf("Error fetching Banner course info: %v\n", err)
 				return
 			}
 
-			// Group sections by type
-			var lectures []string
-			var labs []string
-
+			// Format each section, then print them by link group, since
+			// Banner only accepts linked sections registered together
+			var sections []CourseSection
+			blocks := make(map[string]string)
 			for _, section := range bannerResponse.Data {
 				if section.CourseReferenceNumber == "" {
 					continue
 				}
 
-				details, err := session.fetchSessions(*semesterFlag, section.CourseReferenceNumber)
+				details, err := session.fetchSessions(ctx, *semesterFlag, section.CourseReferenceNumber)
 				if err != nil {
 					fmt.Printf("Error fetching course details: %v\n", err)
 					continue
 				}
+				sections = append(sections, section)
 
 				for _, meetingFaculty := range details.Fmt {
 					mt := meetingFaculty.MeetingTime
@@ -418,26 +983,43 @@ func main() {
 						sectionInfo.WriteString(fmt.Sprintf("Dates: %s to %s\n", mt.StartDate, mt.EndDate))
 					}
 
-					// Add to appropriate group based on section type
-					if strings.HasPrefix(section.Section, "A") {
-						lectures = append(lectures, sectionInfo.String())
-					} else {
-						labs = append(labs, sectionInfo.String())
+					if partners := linkedPartners(section, bannerResponse.Data); len(partners) > 0 {
+						var linked []string
+						for _, p := range partners {
+							linked = append(linked, fmt.Sprintf("%s (CRN: %s)", p.Section, p.CourseReferenceNumber))
+						}
+						sectionInfo.WriteString(fmt.Sprintf("Linked with: %s\n", strings.Join(linked, ", ")))
 					}
-				}
-			}
 
-			if len(lectures) > 0 {
-				fmt.Printf("\nLecture Sections:\n%s\n", strings.Repeat("=", 16))
-				for _, lecture := range lectures {
-					fmt.Print(lecture)
+					blocks[section.CourseReferenceNumber] += sectionInfo.String()
 				}
 			}
 
-			if len(labs) > 0 {
-				fmt.Printf("\nLab/Tutorial Sections:\n%s\n", strings.Repeat("=", 20))
-				for _, lab := range labs {
-					fmt.Print(lab)
+			groups := buildLinkGroups(sections)
+			for _, group := range groups {
+				if group.Key != "" {
+					fmt.Printf("\nLinked Group %s:\n%s\n", group.Key, strings.Repeat("#", len("Linked Group "+group.Key+":")))
+				} else if len(groups) > 1 {
+					fmt.Printf("\nUnlinked Sections:\n%s\n", strings.Repeat("#", len("Unlinked Sections:")))
+				}
+				var scheduleTypes []string
+				byType := make(map[string][]CourseSection)
+				for _, section := range group.Sections {
+					scheduleType := section.ScheduleTypeDescription
+					if scheduleType == "" {
+						scheduleType = "Other"
+					}
+					if _, ok := byType[scheduleType]; !ok {
+						scheduleTypes = append(scheduleTypes, scheduleType)
+					}
+					byType[scheduleType] = append(byType[scheduleType], section)
+				}
+				for _, scheduleType := range scheduleTypes {
+					heading := scheduleType + " Sections:"
+					fmt.Printf("\n%s\n%s\n", heading, strings.Repeat("=", len(heading)))
+					for _, section := range byType[scheduleType] {
+						fmt.Print(blocks[section.CourseReferenceNumber])
+					}
 				}
 			}
 
@@ -448,7 +1030,7 @@ func main() {
 	}
 
 	if *allCoursesFlag {
-		fmt.Println("Loading all courses from courses.json")
+		fmt.Fprintln(progress, "Loading all courses from courses.json")
 
 		courses, err := loadCoursesFromJSON("courses.json")
 		if err != nil {
@@ -456,18 +1038,43 @@ func main() {
 			return
 		}
 
+		if *snapshotFlag != "" {
+			info, err := store.find(*semesterFlag, *snapshotFlag)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			rows, err := store.load(info)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if format == formatSQLite {
+				err = exportSQLite(output, rows, courses)
+			} else {
+				err = writeRows(rows, format, output)
+			}
+			if err != nil {
+				fmt.Fprintf(progress, "Error exporting results: %v\n", err)
+				return
+			}
+			if !isStdout(output) {
+				fmt.Fprintf(progress, "Exported %d course sections from snapshot %s to %s\n", len(rows), info.ID, output)
+			}
+			return
+		}
+
 		session, err := NewSession()
 		if err != nil {
 			fmt.Printf("Error creating session: %v\n", err)
 			return
 		}
 
-		sem := make(chan struct{}, 10)
+		if *dryRunFlag && len(courses) > dryRunLimit {
+			courses = courses[:dryRunLimit]
+		}
 
 		results := make(chan CSVExportRow)
-
-		var wg sync.WaitGroup
-
 		errorCh := make(chan error)
 
 		var csvRows []CSVExportRow
@@ -479,101 +1086,6 @@ func main() {
 			done <- true
 		}()
 
-		// Process each course
-		for i, c := range courses {
-			if *dryRunFlag {
-				if i >= 10 {
-					break
-				}
-			}
-			subject := c.SubjectCode.Name
-			number := strings.TrimPrefix(c.CourseID, subject)
-
-			wg.Add(1)
-			go func(c Course, subject, number string) {
-				defer wg.Done()
-
-				sem <- struct{}{}
-				defer func() { <-sem }()
-
-				fmt.Printf("Fetching details for %s %s (%s)...\n", subject, number, c.Title)
-
-				maxRetries := 3
-				var response *CourseResponse
-				var err error
-
-				for i := 0; i < maxRetries; i++ {
-					response, err = session.fetchCourseInfo(*semesterFlag, subject, number)
-					if err == nil {
-						break
-					}
-					fmt.Printf("Retrying %s %s (%s)...\n", subject, number, c.Title)
-					time.Sleep(1 * time.Second)
-				}
-				if err != nil {
-					errorCh <- fmt.Errorf("error fetching course info for %s %s: %v", subject, number, err)
-					// Even if there's an error, we'll record the course as unavailable
-					results <- CSVExportRow{
-						Term:         *semesterFlag,
-						Subject:      subject,
-						CourseName:   c.Title,
-						CourseNumber: number,
-						Available:    false,
-					}
-					return
-				}
-
-				if len(response.Data) == 0 {
-					results <- CSVExportRow{
-						Term:         *semesterFlag,
-						Subject:      subject,
-						CourseName:   c.Title,
-						CourseNumber: number,
-						Available:    false,
-					}
-					return
-				}
-
-				for _, section := range response.Data {
-					if section.CourseReferenceNumber == "" {
-						continue
-					}
-
-					details, err := session.fetchSessions(*semesterFlag, section.CourseReferenceNumber)
-					if err != nil {
-						errorCh <- fmt.Errorf("error fetching session for CRN %s: %v",
-							section.CourseReferenceNumber, err)
-						continue
-					}
-
-					for _, section := range details.Fmt {
-						mt := section.MeetingTime
-						location := ""
-						if mt.Building != "" && mt.Room != "" {
-							location = fmt.Sprintf("%s %s", mt.Building, mt.Room)
-						}
-
-						row := CSVExportRow{
-							Term:         *semesterFlag,
-							Subject:      subject,
-							CourseName:   c.Title,
-							CourseNumber: number,
-							CRN:          section.CourseReferenceNumber,
-							Section:      section.Section,
-							Time:         fmt.Sprintf("%s-%s", formatTime(mt.BeginTime), formatTime(mt.EndTime)),
-							Days:         getDays(mt),
-							Location:     location,
-							DateRange:    "", // Remove date range if not available in API
-							ScheduleType: mt.MeetingScheduleType,
-							Instructor:   getInstructors(section.Faculty),
-							Available:    true,
-						}
-						results <- row
-					}
-				}
-			}(c, subject, number)
-		}
-
 		errDone := make(chan bool)
 		var errors []error
 		go func() {
@@ -583,7 +1095,44 @@ func main() {
 			errDone <- true
 		}()
 
-		wg.Wait()
+		// Dry runs aren't journaled, so they never clobber a real crawl's
+		// resume state
+		var journal *crawlJournal
+		if !*dryRunFlag {
+			journal, err = openJournal(*journalFlag, *semesterFlag, *resumeFlag)
+			if err != nil {
+				fmt.Printf("Error opening journal: %v\n", err)
+				return
+			}
+			defer journal.Close()
+		}
+		if *resumeFlag {
+			fmt.Fprintf(progress, "Resuming from %s: retrying %d failed entries and anything not yet fetched\n",
+				*journalFlag, len(journal.failures()))
+		}
+
+		stop, abort, release := interruptContexts(context.Background())
+		defer release()
+
+		cr := &crawler{
+			session: session,
+			term:    *semesterFlag,
+			results: results,
+			errorCh: errorCh,
+			stop:    stop,
+			journal: journal,
+		}
+		if *dryRunFlag {
+			cr.limit = dryRunLimit
+		}
+		switch *crawlFlag {
+		case crawlPerSubject:
+			cr.crawlBySubject(abort, courses, false)
+		case crawlWholeTerm:
+			cr.crawlBySubject(abort, courses, true)
+		default:
+			cr.crawlByCourse(abort, courses)
+		}
 
 		close(results)
 		close(errorCh)
@@ -592,18 +1141,59 @@ func main() {
 		<-errDone
 
 		if len(errors) > 0 {
-			fmt.Println("\nErrors occurred during processing:")
+			fmt.Fprintln(progress, "\nErrors occurred during processing:")
 			for _, err := range errors {
-				fmt.Printf("- %v\n", err)
+				fmt.Fprintf(progress, "- %v\n", err)
 			}
 		}
 
-		if err := exportToCSV(csvRows, "courses.csv"); err != nil {
-			fmt.Printf("Error exporting to CSV: %v\n", err)
+		if cr.stopped() && !isStdout(output) {
+			output = incompleteName(output)
+		}
+		if format == formatSQLite {
+			err = exportSQLite(output, csvRows, courses)
+		} else {
+			err = writeRows(csvRows, format, output)
+		}
+		if err != nil {
+			fmt.Fprintf(progress, "Error exporting results: %v\n", err)
+			return
+		}
+
+		destination := output
+		if isStdout(output) {
+			destination = "stdout"
+		}
+		if failures := journal.failures(); len(failures) > 0 {
+			fmt.Fprintf(progress, "%d courses or sections failed; rerun with -resume to retry them\n", len(failures))
+		} else if cr.stopped() && journal != nil {
+			fmt.Fprintln(progress, "Rerun with -resume to continue where this crawl stopped")
+		}
+		if cr.stopped() {
+			fmt.Fprintf(progress, "Crawl INCOMPLETE: exported %d course sections collected before the interrupt to %s\n", len(csvRows), destination)
 			return
 		}
+		fmt.Fprintf(progress, "Exported %d course sections to %s\n", len(csvRows), destination)
 
-		fmt.Printf("Exported %d course sections to courses.csv\n", len(csvRows))
+		if journal != nil && len(journal.failures()) == 0 {
+			if err := journal.finish(); err != nil {
+				fmt.Fprintf(progress, "Error writing journal: %v\n", err)
+			}
+		}
+		if *dryRunFlag || *noSnapshotFlag || len(journal.failures()) > 0 {
+			return
+		}
+		previous, _ := store.find(*semesterFlag, "latest")
+		info, err := store.save(*semesterFlag, csvRows, time.Now())
+		if err != nil {
+			fmt.Fprintf(progress, "Error saving snapshot: %v\n", err)
+			return
+		}
+		unchanged := ""
+		if previous.Hash == info.Hash {
+			unchanged = fmt.Sprintf(" (unchanged since %s)", previous.ID)
+		}
+		fmt.Fprintf(progress, "Saved snapshot %s of %s%s\n", info.ID, termName(info.Term), unchanged)
 		return
 	}
 
@@ -612,4 +1202,21 @@ func main() {
 	fmt.Println("  --course [SUBJECT COURSE#] : fetch a single course (e.g., --course CSC 110).")
 	fmt.Println("  --courses [SUBJECT1 NUMBER1 SUBJECT2 NUMBER2 ...] : fetch multiple courses info.")
 	fmt.Println("  --all                      : fetch all courses from courses.json and export to CSV.")
+	fmt.Println("  --schedule [SUBJECT1 NUMBER1 ...] : generate conflict-free schedules (see --max-schedules).")
+	fmt.Println("  --ics [CRN1 CRN2 ...]      : write the sections' weekly meetings to schedule.ics.")
+	fmt.Println("  --catalog-sync             : regenerate courses.json from the Kuali catalog.")
+	fmt.Println("  --snapshots                : list the snapshots kept of each --all crawl (see --snapshot, --prune-snapshots).")
+	fmt.Println("  --section-history [CRN]    : show when a section appeared or disappeared across snapshots.")
+	fmt.Println("  --diff [OLD NEW]           : list sections added, cancelled or changed between two crawls.")
+	fmt.Println("  --watch [CRN1 CRN2 ...]    : poll sections and notify when seats or waitlists open (see --webhook, --smtp).")
+	fmt.Println("  --collect [SUBJECT ...]    : record enrollment and waitlist numbers every --interval to --enrollment-db.")
+	fmt.Println("  --enrollment-report [SUBJECT [COURSE#]] : show fill curves, fill dates and peak waitlists from --collect.")
+	fmt.Println("  --serve                    : serve crawled terms, courses and sections as a JSON API on --addr (see --refresh).")
+	fmt.Println("  --terms                    : list the terms Banner offers.")
+	fmt.Println("  --search [WORDS ...]       : search course titles and descriptions offline (subject:CSC, level:300).")
+	fmt.Println("  --requirements [SUBJECT COURSE#] : show a course's prerequisites as an outline or JSON.")
+	fmt.Println("  --prereqs [SUBJECT COURSE#] : show every course needed before a course.")
+	fmt.Println("  --unlocks [SUBJECT COURSE#] : list the courses a course leads to.")
+	fmt.Println("  --graph                    : export the prerequisite graph as Graphviz DOT or JSON.")
+	fmt.Println("  --eligible                 : check a --transcript against every course's prerequisites (see --offered).")
 }
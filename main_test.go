@@ -0,0 +1,498 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func readExport(t *testing.T, path string) [][]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records[1:]
+}
+
+// exportedCRNs returns the CRN column of an export, plus "-" followed by the
+// course for each unavailable course row.
+func exportedCRNs(rows [][]string) []string {
+	var crns []string
+	for _, row := range rows {
+		if row[4] == "" {
+			crns = append(crns, "-"+row[1]+row[3])
+			continue
+		}
+		crns = append(crns, row[4])
+	}
+	sort.Strings(crns)
+	return crns
+}
+
+func TestCourseLookup(t *testing.T) {
+	fake := newFakeUVic(t)
+	out := runMain(t, fake, scratchDir(t), "-course", "CSC", "110")
+
+	for _, want := range []string{
+		"CSC 110: Fundamentals of Programming I",
+		"Credits: 1.5",
+		"Section A01 (CRN: 20001)",
+		// B03 is on the third page of results
+		"Section B03 (CRN: 20013)",
+		"Instructor: Doe, Jane",
+		"Enrollment: 200/200 (Waitlist: 12/50)",
+		"Linked with: B01 (CRN: 20011), B02 (CRN: 20012)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Each link group is printed together, its sections by schedule type
+	last := -1
+	for _, want := range []string{
+		"Linked Group L1:", "Lecture Sections:", "Section A01", "Lab Sections:", "Section B01", "Section B02",
+		"Linked Group L2:", "Lecture Sections:", "Section A02", "Lab Sections:", "Section B03",
+	} {
+		i := strings.Index(out[last+1:], want)
+		if i < 0 {
+			t.Fatalf("%q missing or out of order:\n%s", want, out)
+		}
+		last += 1 + i
+	}
+}
+
+func TestCoursesLookup(t *testing.T) {
+	fake := newFakeUVic(t)
+	out := runMain(t, fake, scratchDir(t), "-courses", "CSC", "110", "MATH", "100")
+
+	for _, want := range []string{
+		"CSC 110: Fundamentals of Programming I",
+		"MATH 100: Calculus I",
+		"Section T01 (CRN: 21011)",
+		"Instructor: Nguyen, Sam",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestScheduleHonoursLinksAndConflicts(t *testing.T) {
+	fake := newFakeUVic(t)
+	out := runMain(t, fake, scratchDir(t), "-schedule", "CSC", "110", "MATH", "100")
+
+	// A01 clashes with MATH 100 A01, B01/B02 are only linked to A01 and B03
+	// only to A02, leaving exactly one registrable timetable.
+	if !strings.Contains(out, "Found 1 conflict-free schedules") {
+		t.Fatalf("unexpected schedules:\n%s", out)
+	}
+	for _, crn := range []string{"20002", "20013", "21001", "21011"} {
+		if !strings.Contains(out, "(CRN: "+crn+")") {
+			t.Errorf("schedule missing CRN %s:\n%s", crn, out)
+		}
+	}
+}
+
+func TestAllExportsEveryCrawlMode(t *testing.T) {
+	want := []string{"-CSC111", "20001", "20002", "20011", "20012", "20013", "21001", "21011"}
+
+	for _, mode := range []string{crawlPerCourse, crawlPerSubject, crawlWholeTerm} {
+		t.Run(mode, func(t *testing.T) {
+			fake := newFakeUVic(t)
+			dir := scratchDir(t)
+			out := runMain(t, fake, dir, "-all", "-crawl="+mode)
+
+			if strings.Contains(out, "Errors occurred") {
+				t.Fatalf("crawl reported errors:\n%s", out)
+			}
+			got := exportedCRNs(readExport(t, filepath.Join(dir, "courses.csv")))
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("exported %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDryRunLimitsWhatIsFetched(t *testing.T) {
+	saved := dryRunLimit
+	dryRunLimit = 3
+	defer func() { dryRunLimit = saved }()
+
+	const search = "/StudentRegistrationSsb/ssb/searchResults/searchResults"
+	for _, mode := range []string{crawlPerCourse, crawlPerSubject, crawlWholeTerm} {
+		t.Run(mode, func(t *testing.T) {
+			fake := newFakeUVic(t)
+			dir := scratchDir(t)
+			out := runMain(t, fake, dir, "-all", "-dry-run", "-crawl="+mode)
+			if strings.Contains(out, "Errors occurred") {
+				t.Fatalf("crawl reported errors:\n%s", out)
+			}
+
+			var sections int
+			for _, crn := range exportedCRNs(readExport(t, filepath.Join(dir, "courses.csv"))) {
+				if !strings.HasPrefix(crn, "-") {
+					sections++
+				}
+			}
+			if sections == 0 || sections > dryRunLimit {
+				t.Errorf("dry run exported %d sections, want 1 to %d", sections, dryRunLimit)
+			}
+			// Pages hold two sections, so three sections take at most two
+			if mode == crawlWholeTerm && fake.count(search) > 2 {
+				t.Errorf("dry run fetched %d search pages, want at most 2", fake.count(search))
+			}
+		})
+	}
+}
+
+// unfinishJournal drops the finish entry, as if the crawl was interrupted
+// just before it completed.
+func unfinishJournal(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if !strings.Contains(line, `"kind":"finish"`) {
+			kept = append(kept, line)
+		}
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(kept, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllResumeSkipsCompletedWork(t *testing.T) {
+	for _, mode := range []string{crawlPerCourse, crawlPerSubject, crawlWholeTerm} {
+		t.Run(mode, func(t *testing.T) {
+			fake := newFakeUVic(t)
+			dir := scratchDir(t)
+			runMain(t, fake, dir, "-all", "-crawl="+mode)
+			first := readExport(t, filepath.Join(dir, "courses.csv"))
+			unfinishJournal(t, filepath.Join(dir, "crawl.journal"))
+			requests := fake.count("/StudentRegistrationSsb/ssb/searchResults/searchResults") +
+				fake.count("/StudentRegistrationSsb/ssb/searchResults/getFacultyMeetingTimes")
+
+			out := runMain(t, fake, dir, "-all", "-resume", "-crawl="+mode)
+			if !strings.Contains(out, "Resuming from crawl.journal") {
+				t.Fatalf("resume didn't run:\n%s", out)
+			}
+			if got := fake.count("/StudentRegistrationSsb/ssb/searchResults/searchResults") +
+				fake.count("/StudentRegistrationSsb/ssb/searchResults/getFacultyMeetingTimes"); got != requests {
+				t.Errorf("resume made %d new requests, want none", got-requests)
+			}
+			second := readExport(t, filepath.Join(dir, "courses.csv"))
+			if strings.Join(exportedCRNs(second), ",") != strings.Join(exportedCRNs(first), ",") {
+				t.Errorf("resumed export %v differs from original %v", exportedCRNs(second), exportedCRNs(first))
+			}
+		})
+	}
+}
+
+func TestDryRunKeepsTheJournal(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	runMain(t, fake, dir, "-all")
+	unfinishJournal(t, filepath.Join(dir, "crawl.journal"))
+	interrupted, err := os.ReadFile(filepath.Join(dir, "crawl.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runMain(t, fake, dir, "-all", "-dry-run", "-o", "dry.csv")
+	after, err := os.ReadFile(filepath.Join(dir, "crawl.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(interrupted) {
+		t.Error("a dry run rewrote the journal")
+	}
+
+	if out := runMain(t, fake, dir, "-all", "-dry-run", "-resume"); !strings.Contains(out, "cannot be used together") {
+		t.Errorf("-dry-run -resume was accepted:\n%s", out)
+	}
+
+	searches := fake.count("/StudentRegistrationSsb/ssb/searchResults/searchResults")
+	out := runMain(t, fake, dir, "-all", "-resume")
+	if !strings.Contains(out, "Resuming from crawl.journal") {
+		t.Fatalf("resume after a dry run didn't run:\n%s", out)
+	}
+	if got := fake.count("/StudentRegistrationSsb/ssb/searchResults/searchResults"); got != searches {
+		t.Errorf("resume after a dry run made %d new searches, want none", got-searches)
+	}
+}
+
+func TestJournalHoldsOneCrawl(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	path := filepath.Join(dir, "crawl.journal")
+
+	out := runMain(t, fake, dir, "-all", "-resume")
+	if !strings.Contains(out, "nothing to resume") {
+		t.Errorf("resume without a journal:\n%s", out)
+	}
+
+	runMain(t, fake, dir, "-all")
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runMain(t, fake, dir, "-all")
+	second, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != len(first) {
+		t.Errorf("journal grew from %d to %d bytes on a fresh crawl", len(first), len(second))
+	}
+	// Each section's rows are stored once, on its CRN entry
+	if n := strings.Count(string(second), `"CRN":"20001"`); n != 1 {
+		t.Errorf("CRN 20001's rows are stored %d times", n)
+	}
+
+	out = runMain(t, fake, dir, "-all", "-resume")
+	if !strings.Contains(out, "nothing to resume") || !strings.Contains(out, "already finished") {
+		t.Errorf("resume of a finished crawl:\n%s", out)
+	}
+}
+
+func TestSessionRecoversFromExpiry(t *testing.T) {
+	fake := newFakeUVic(t)
+	saved := bannerBaseURL
+	bannerBaseURL = fake.bannerURL()
+	defer func() { bannerBaseURL = saved }()
+
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	response, err := session.fetchCourseInfo(ctx, "202501", "CSC", "110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Data) != 5 || response.TotalCount != 5 {
+		t.Fatalf("got %d of %d sections, want 5", len(response.Data), response.TotalCount)
+	}
+	if _, err := session.fetchSessions(ctx, "202501", "20001"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.count("/StudentRegistrationSsb/ssb/term/search"); got != 1 {
+		t.Fatalf("term handshake ran %d times before expiry, want 1", got)
+	}
+
+	fake.expireSessions()
+	details, err := session.fetchSessions(ctx, "202501", "20001")
+	if err != nil {
+		t.Fatalf("request after expiry: %v", err)
+	}
+	if len(details.Fmt) != 1 || details.Fmt[0].MeetingTime.Room != "123" {
+		t.Errorf("unexpected meeting times after expiry: %+v", details.Fmt)
+	}
+	if got := fake.count("/StudentRegistrationSsb/ssb/term/search"); got != 2 {
+		t.Errorf("term handshake ran %d times, want 2", got)
+	}
+}
+
+func TestAllPopulatesEveryColumn(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	runMain(t, fake, dir, "-all")
+
+	file, err := os.Open(filepath.Join(dir, "courses.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := records[0]
+	for _, record := range records[1:] {
+		if record[4] != "20001" {
+			continue
+		}
+		for i, value := range record {
+			if value == "" {
+				t.Errorf("column %q is empty", header[i])
+			}
+		}
+		row := make(map[string]string)
+		for i, value := range record {
+			row[header[i]] = value
+		}
+		for column, want := range map[string]string{
+			"Building Name":      "Engineering & Computer Science Building",
+			"Date Range":         "01/06/2025 - 04/04/2025",
+			"Instructor Email":   "jdoe@uvic.ca",
+			"Maximum Enrollment": "200",
+			"Wait Capacity":      "50",
+			"Units":              "1.5",
+		} {
+			if row[column] != want {
+				t.Errorf("%s = %q, want %q", column, row[column], want)
+			}
+		}
+		return
+	}
+	t.Fatal("CRN 20001 missing from export")
+}
+
+func TestSearchPagesDontHoldTheSession(t *testing.T) {
+	fake := newFakeUVic(t)
+	savedURL, savedTransport := bannerBaseURL, sharedTransport
+	bannerBaseURL, sharedTransport = fake.bannerURL(), testScheduler(0, 4, 0)
+	defer func() { bannerBaseURL, sharedTransport = savedURL, savedTransport }()
+
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Another search between pages must neither block nor leak into this one
+	var crns []string
+	for section, err := range session.searchSections(ctx, "202501", "CSC", "110", nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		crns = append(crns, section.CourseReferenceNumber)
+		if _, err := session.findSection(ctx, "202501", "21001"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(crns, ",") != "20001,20002,20011,20012,20013" {
+		t.Errorf("CSC 110 search returned %v", crns)
+	}
+	// One reset per lookup, plus one before each of the search's 3 pages
+	if got := fake.count("/StudentRegistrationSsb/ssb/classSearch/resetDataForm"); got != 5+3 {
+		t.Errorf("criteria reset %d times, want 8", got)
+	}
+}
+
+func TestSessionKeepsTermBoundForEachRequest(t *testing.T) {
+	fake := newFakeUVic(t)
+	fake.latency = 5 * time.Millisecond
+	savedURL, savedTransport := bannerBaseURL, sharedTransport
+	bannerBaseURL, sharedTransport = fake.bannerURL(), testScheduler(0, 8, 0)
+	defer func() { bannerBaseURL, sharedTransport = savedURL, savedTransport }()
+
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake rejects requests for a term other than the session's, which
+	// only happens if the binding switches mid-request
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		term := []string{"202501", "202505"}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := session.fetchSessions(context.Background(), term, "20001"); err != nil {
+				t.Errorf("%s: %v", term, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := fake.count("rejected"); got != 0 {
+		t.Errorf("%d requests ran while the session was bound to another term", got)
+	}
+}
+
+func TestRebindingResetsSearchCriteria(t *testing.T) {
+	fake := newFakeUVic(t)
+	savedURL, savedTransport := bannerBaseURL, sharedTransport
+	bannerBaseURL, sharedTransport = fake.bannerURL(), testScheduler(0, 4, 0)
+	defer func() { bannerBaseURL, sharedTransport = savedURL, savedTransport }()
+
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Binding to another term between pages leaves Banner's criteria behind
+	// the other term's handshake, so the next page resets them again
+	const reset = "/StudentRegistrationSsb/ssb/classSearch/resetDataForm"
+	var crns []string
+	for section, err := range session.searchSections(ctx, "202501", "CSC", "110", nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		crns = append(crns, section.CourseReferenceNumber)
+		if len(crns) == 2 {
+			if _, err := session.fetchSessions(ctx, "202505", "20001"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(crns) != 5 {
+		t.Errorf("CSC 110 search returned %v", crns)
+	}
+	if got := fake.count(reset); got != 2 {
+		t.Errorf("criteria reset %d times, want 2", got)
+	}
+}
+
+func TestHandshakeDoesNotHoldTheSessionLock(t *testing.T) {
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/term/termSelection") {
+			close(entered)
+			<-unblock
+		}
+	}))
+	defer srv.Close()
+	savedURL, savedTransport := bannerBaseURL, sharedTransport
+	bannerBaseURL, sharedTransport = srv.URL, testScheduler(0, 4, 0)
+	defer func() { bannerBaseURL, sharedTransport = savedURL, savedTransport }()
+
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bound := make(chan error)
+	go func() {
+		_, err := session.bind(context.Background(), "202501")
+		bound <- err
+	}()
+	<-entered
+
+	locked := make(chan struct{})
+	go func() {
+		session.expire(-1)
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Error("the session lock was held across the handshake")
+	}
+	close(unblock)
+	if err := <-bound; err != nil {
+		t.Fatal(err)
+	}
+	session.release()
+}
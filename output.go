@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats accepted by -format
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatNDJSON   = "ndjson"
+	formatCSV      = "csv"
+	formatMarkdown = "markdown"
+	formatSQLite   = "sqlite"
+	formatDOT      = "dot"
+)
+
+var outputFormats = []string{formatText, formatJSON, formatNDJSON, formatCSV, formatMarkdown, formatSQLite, formatDOT}
+
+func checkFormat(format string) error {
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q (want one of %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// progress receives status messages. It is switched to stderr whenever
+// results go to stdout, so they can be piped into jq and friends.
+var progress io.Writer = os.Stdout
+
+// RowWriter writes export rows in one output format. Close flushes anything
+// buffered but does not close the underlying writer.
+type RowWriter interface {
+	WriteRow(row CSVExportRow) error
+	Close() error
+}
+
+func NewRowWriter(format string, w io.Writer) (RowWriter, error) {
+	switch format {
+	case formatCSV:
+		return &csvRowWriter{w: csv.NewWriter(w)}, nil
+	case formatJSON:
+		return &jsonRowWriter{w: w, rows: []CourseOutput{}}, nil
+	case formatNDJSON:
+		return &ndjsonRowWriter{enc: json.NewEncoder(w)}, nil
+	case formatMarkdown:
+		return &markdownRowWriter{w: w}, nil
+	case formatText:
+		return &textRowWriter{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}, nil
+	case formatSQLite:
+		return nil, fmt.Errorf("sqlite output is a database file, not a stream")
+	case formatDOT:
+		return nil, fmt.Errorf("dot output is only available for -graph")
+	}
+	return nil, checkFormat(format)
+}
+
+var csvHeader = []string{
+	"Term", "Subject", "Course Name", "Course Number", "CRN", "Section",
+	"Time", "Days", "Location", "Date Range", "Schedule Type",
+	"Instructor", "Instructional Method", "Units", "Available",
+	"Campus", "Campus Description", "Building Code", "Building Name", "Room Number",
+	"Meeting Type", "Meeting Description", "Instructor Email", "Credit Hours",
+	"Start Date", "End Date", "Enrollment", "Maximum Enrollment", "Seats Available",
+	"Wait Count", "Wait Capacity", "Link Group",
+}
+
+func csvRecord(row CSVExportRow) []string {
+	return []string{
+		row.Term,
+		row.Subject,
+		row.CourseName,
+		row.CourseNumber,
+		row.CRN,
+		row.Section,
+		row.Time,
+		row.Days,
+		row.Location,
+		row.DateRange,
+		row.ScheduleType,
+		row.Instructor,
+		row.InstructionalMethod,
+		row.Units,
+		fmt.Sprintf("%v", row.Available),
+		row.Campus,
+		row.CampusDescription,
+		row.BuildingCode,
+		row.BuildingName,
+		row.RoomNumber,
+		row.MeetingType,
+		row.MeetingDescription,
+		row.InstructorEmail,
+		row.CreditHours,
+		row.StartDate,
+		row.EndDate,
+		strconv.Itoa(row.Enrollment),
+		strconv.Itoa(row.MaximumEnrollment),
+		strconv.Itoa(row.SeatsAvailable),
+		strconv.Itoa(row.WaitCount),
+		strconv.Itoa(row.WaitCapacity),
+		row.LinkGroup,
+	}
+}
+
+type csvRowWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvRowWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	if err := c.w.Write(csvHeader); err != nil {
+		return fmt.Errorf("error writing header: %v", err)
+	}
+	return nil
+}
+
+func (c *csvRowWriter) WriteRow(row CSVExportRow) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	if err := c.w.Write(csvRecord(row)); err != nil {
+		return fmt.Errorf("error writing record: %v", err)
+	}
+	return nil
+}
+
+func (c *csvRowWriter) Close() error {
+	// An empty export still gets its header
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// instructorNames drops the "(email)" suffixes getInstructors adds.
+func instructorNames(row CSVExportRow) string {
+	names := row.Instructor
+	for _, email := range strings.Split(row.InstructorEmail, ", ") {
+		if email != "" {
+			names = strings.ReplaceAll(names, " ("+email+")", "")
+		}
+	}
+	return names
+}
+
+func toCourseOutput(row CSVExportRow) CourseOutput {
+	out := CourseOutput{
+		Term:            row.Term,
+		CRN:             row.CRN,
+		Subject:         row.Subject,
+		CourseNumber:    row.CourseNumber,
+		Section:         row.Section,
+		Title:           row.CourseName,
+		Professor:       instructorNames(row),
+		Email:           row.InstructorEmail,
+		Schedule:        row.Time,
+		ScheduleType:    row.ScheduleType,
+		Location:        row.Location,
+		Building:        row.BuildingName,
+		Room:            row.RoomNumber,
+		Campus:          row.CampusDescription,
+		Days:            row.Days,
+		CreditHours:     row.CreditHours,
+		InstructionType: row.InstructionalMethod,
+		DateRange:       row.DateRange,
+		StartDate:       row.StartDate,
+		EndDate:         row.EndDate,
+		Available:       row.Available,
+		LinkGroup:       row.LinkGroup,
+	}
+	if row.Available {
+		out.Enrollment = fmt.Sprintf("%d/%d", row.Enrollment, row.MaximumEnrollment)
+		out.SeatsAvailable = row.SeatsAvailable
+		out.WaitCount = row.WaitCount
+		out.WaitCapacity = row.WaitCapacity
+	}
+	return out
+}
+
+type jsonRowWriter struct {
+	w    io.Writer
+	rows []CourseOutput
+}
+
+func (j *jsonRowWriter) WriteRow(row CSVExportRow) error {
+	j.rows = append(j.rows, toCourseOutput(row))
+	return nil
+}
+
+func (j *jsonRowWriter) Close() error {
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(j.rows)
+}
+
+type ndjsonRowWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonRowWriter) WriteRow(row CSVExportRow) error {
+	return n.enc.Encode(toCourseOutput(row))
+}
+
+func (n *ndjsonRowWriter) Close() error {
+	return nil
+}
+
+// summaryColumns are the columns shown by the human-readable formats.
+var summaryColumns = []struct {
+	header string
+	value  func(CSVExportRow) string
+}{
+	{"Term", func(r CSVExportRow) string { return r.Term }},
+	{"Course", func(r CSVExportRow) string { return r.Subject + " " + r.CourseNumber }},
+	{"Title", func(r CSVExportRow) string { return r.CourseName }},
+	{"CRN", func(r CSVExportRow) string { return r.CRN }},
+	{"Section", func(r CSVExportRow) string { return r.Section }},
+	{"Type", func(r CSVExportRow) string { return r.ScheduleType }},
+	{"Days", func(r CSVExportRow) string { return r.Days }},
+	{"Time", func(r CSVExportRow) string { return r.Time }},
+	{"Location", func(r CSVExportRow) string { return r.Location }},
+	{"Instructor", instructorNames},
+	{"Enrollment", func(r CSVExportRow) string {
+		if !r.Available {
+			return "not offered"
+		}
+		return fmt.Sprintf("%d/%d", r.Enrollment, r.MaximumEnrollment)
+	}},
+}
+
+type markdownRowWriter struct {
+	w             io.Writer
+	headerWritten bool
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+func (m *markdownRowWriter) writeHeader() error {
+	if m.headerWritten {
+		return nil
+	}
+	m.headerWritten = true
+	var header, rule []string
+	for _, col := range summaryColumns {
+		header = append(header, col.header)
+		rule = append(rule, "---")
+	}
+	_, err := fmt.Fprintf(m.w, "| %s |\n| %s |\n", strings.Join(header, " | "), strings.Join(rule, " | "))
+	return err
+}
+
+func (m *markdownRowWriter) WriteRow(row CSVExportRow) error {
+	if err := m.writeHeader(); err != nil {
+		return err
+	}
+	var cells []string
+	for _, col := range summaryColumns {
+		cells = append(cells, markdownCell(col.value(row)))
+	}
+	_, err := fmt.Fprintf(m.w, "| %s |\n", strings.Join(cells, " | "))
+	return err
+}
+
+func (m *markdownRowWriter) Close() error {
+	return m.writeHeader()
+}
+
+type textRowWriter struct {
+	w             *tabwriter.Writer
+	headerWritten bool
+}
+
+func (t *textRowWriter) writeHeader() error {
+	if t.headerWritten {
+		return nil
+	}
+	t.headerWritten = true
+	var header []string
+	for _, col := range summaryColumns {
+		header = append(header, col.header)
+	}
+	_, err := fmt.Fprintln(t.w, strings.Join(header, "\t"))
+	return err
+}
+
+func (t *textRowWriter) WriteRow(row CSVExportRow) error {
+	if err := t.writeHeader(); err != nil {
+		return err
+	}
+	var cells []string
+	for _, col := range summaryColumns {
+		cells = append(cells, col.value(row))
+	}
+	_, err := fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+	return err
+}
+
+func (t *textRowWriter) Close() error {
+	if err := t.writeHeader(); err != nil {
+		return err
+	}
+	return t.w.Flush()
+}
+
+func isStdout(dest string) bool {
+	return dest == "" || dest == "-"
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// openOutput opens dest for writing, or stdout when dest is "" or "-".
+func openOutput(dest string) (io.WriteCloser, error) {
+	if isStdout(dest) {
+		return nopCloser{os.Stdout}, nil
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %v", err)
+	}
+	return out, nil
+}
+
+func writeRows(rows []CSVExportRow, format, dest string) error {
+	if format == formatSQLite {
+		return exportSQLite(dest, rows, nil)
+	}
+
+	out, err := openOutput(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer, err := NewRowWriter(format, out)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
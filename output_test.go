@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCourseLookupAsJSON(t *testing.T) {
+	fake := newFakeUVic(t)
+	out := runMain(t, fake, scratchDir(t), "-format=json", "-course", "CSC", "110")
+
+	var courses []CourseOutput
+	if err := json.Unmarshal([]byte(out), &courses); err != nil {
+		t.Fatalf("stdout is not a JSON array: %v\n%s", err, out)
+	}
+	if len(courses) != 5 {
+		t.Fatalf("got %d sections, want 5", len(courses))
+	}
+	first := courses[0]
+	if first.CRN != "20001" || first.Term != "202501" || first.Professor != "Doe, Jane" {
+		t.Errorf("unexpected first section: %+v", first)
+	}
+	if full := courses[1]; full.Enrollment != "200/200" || full.SeatsAvailable != 0 || full.WaitCount != 12 {
+		t.Errorf("unexpected enrollment for A02: %+v", full)
+	}
+}
+
+func TestAllWritesNDJSONToFile(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	runMain(t, fake, dir, "-all", "-format=ndjson", "-o", "courses.ndjson")
+
+	file, err := os.Open(filepath.Join(dir, "courses.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var course CourseOutput
+		if err := json.Unmarshal(scanner.Bytes(), &course); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		lines++
+	}
+	// Seven sections plus the row for CSC 111, which is not offered
+	if lines != 8 {
+		t.Errorf("got %d lines, want 8", lines)
+	}
+}
+
+func TestMarkdownEscapesPipes(t *testing.T) {
+	var out strings.Builder
+	writer, err := NewRowWriter(formatMarkdown, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.WriteRow(CSVExportRow{Subject: "CSC", CourseNumber: "110", CourseName: "A | B", Available: true})
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want header, rule and one row:\n%s", len(lines), out.String())
+	}
+	if !strings.Contains(lines[2], `A \| B`) {
+		t.Errorf("pipe not escaped: %s", lines[2])
+	}
+}
+
+func TestUnknownFormatIsRejected(t *testing.T) {
+	if _, err := NewRowWriter("xml", os.Stdout); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Requirement kinds of a parsed Kuali rule.
+const (
+	reqAllOf      = "all_of"
+	reqNOf        = "n_of"
+	reqConcurrent = "concurrent"
+	reqMinGrade   = "min_grade"
+	reqCourse     = "course"
+	reqText       = "text"
+)
+
+// Requirement is one node of a course's prerequisite rule. Groups (all_of,
+// n_of, concurrent, min_grade) need Count of their Children satisfied;
+// concurrent groups may also be satisfied by enrolling in the same term.
+// Text nodes hold rules Kuali only states in words, such as program
+// admission or standing, and may still carry children.
+type Requirement struct {
+	Kind     string         `json:"kind"`
+	Count    int            `json:"count,omitempty"`
+	Grade    string         `json:"grade,omitempty"`
+	Course   string         `json:"course,omitempty"`
+	Title    string         `json:"title,omitempty"`
+	Credits  string         `json:"credits,omitempty"`
+	Text     string         `json:"text,omitempty"`
+	Children []*Requirement `json:"children,omitempty"`
+}
+
+var (
+	allOfPattern      = regexp.MustCompile(`(?i)^complete all of`)
+	nOfPattern        = regexp.MustCompile(`(?i)^complete (?:at least )?(\d+) of`)
+	concurrentPattern = regexp.MustCompile(`(?i)^completed? or (?:be )?concurrently enrolled in (all|(?:at least )?\d+) of`)
+	minGradePattern   = regexp.MustCompile(`(?i)^earn a minimum grade of (\S+) in (each|(?:at least )?\d+) of`)
+	countPattern      = regexp.MustCompile(`\d+`)
+	creditsPattern    = regexp.MustCompile(`\s*\(([\d.]+)\)\s*$`)
+)
+
+// parsePrerequisites parses Kuali's rule view HTML, as found in
+// PreOrCorequisites, into a requirement tree. It returns nil when there
+// is no rule.
+func parsePrerequisites(rule string) (*Requirement, error) {
+	if strings.TrimSpace(rule) == "" {
+		return nil, nil
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(rule), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing prerequisites: %v", err)
+	}
+
+	var reqs []*Requirement
+	for _, n := range nodes {
+		if r := parseBlock(n); r != nil {
+			reqs = append(reqs, r)
+		}
+	}
+	return combine(reqs), nil
+}
+
+// combine joins sibling rules, which Kuali means as all being required.
+func combine(reqs []*Requirement) *Requirement {
+	switch len(reqs) {
+	case 0:
+		return nil
+	case 1:
+		return reqs[0]
+	}
+	return &Requirement{Kind: reqAllOf, Count: len(reqs), Children: reqs}
+}
+
+// parseBlock finds the rules in a wrapper element. A rule is an element
+// whose first child is its heading <span>.
+func parseBlock(n *html.Node) *Requirement {
+	switch n.Type {
+	case html.TextNode:
+		if text := collapse(n.Data); text != "" {
+			return &Requirement{Kind: reqText, Text: text}
+		}
+		return nil
+	case html.ElementNode:
+	default:
+		return nil
+	}
+	if first := firstElement(n); (first != nil && first.DataAtom == atom.Span) || n.DataAtom == atom.Li {
+		return parseRule(n)
+	}
+
+	var reqs []*Requirement
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if r := parseBlock(c); r != nil {
+			reqs = append(reqs, r)
+		}
+	}
+	return combine(reqs)
+}
+
+// parseRule parses a heading and the list of rules under it, or a single
+// course or free text item when there is no list.
+func parseRule(n *html.Node) *Requirement {
+	list := ruleList(n)
+	if list == nil {
+		if link := courseLink(n); link != nil {
+			return parseCourse(n, link)
+		}
+		if text := collapse(textContent(n)); text != "" {
+			return &Requirement{Kind: reqText, Text: text}
+		}
+		return nil
+	}
+
+	var children []*Requirement
+	for li := list.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+		if r := parseRule(li); r != nil {
+			children = append(children, r)
+		}
+	}
+
+	heading := ""
+	if span := firstElement(n); span != nil && span.DataAtom == atom.Span {
+		heading = collapse(textContent(span))
+	}
+	heading = strings.TrimSuffix(strings.TrimSpace(heading), ":")
+
+	req := &Requirement{Kind: reqText, Text: heading, Count: len(children), Children: children}
+	switch {
+	case allOfPattern.MatchString(heading):
+		req.Kind, req.Text = reqAllOf, ""
+	case nOfPattern.MatchString(heading):
+		req.Kind, req.Text = reqNOf, ""
+		req.Count, _ = strconv.Atoi(nOfPattern.FindStringSubmatch(heading)[1])
+	case concurrentPattern.MatchString(heading):
+		req.Kind, req.Text = reqConcurrent, ""
+		req.Count = parseCount(concurrentPattern.FindStringSubmatch(heading)[1], len(children))
+	case minGradePattern.MatchString(heading):
+		m := minGradePattern.FindStringSubmatch(heading)
+		req.Kind, req.Text, req.Grade = reqMinGrade, "", m[1]
+		req.Count = parseCount(m[2], len(children))
+	}
+	return req
+}
+
+func parseCount(s string, all int) int {
+	if n, err := strconv.Atoi(countPattern.FindString(s)); err == nil {
+		return n
+	}
+	return all
+}
+
+// parseCourse reads "<a>MATH120</a> - Precalculus Mathematics (1.5)".
+func parseCourse(n, link *html.Node) *Requirement {
+	req := &Requirement{Kind: reqCourse, Course: collapse(textContent(link))}
+
+	var rest strings.Builder
+	seen := false
+	var walk func(*html.Node)
+	walk = func(c *html.Node) {
+		if c == link {
+			seen = true
+			return
+		}
+		if c.Type == html.TextNode && seen {
+			rest.WriteString(c.Data)
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+
+	title := collapse(rest.String())
+	if m := creditsPattern.FindStringSubmatch(title); m != nil {
+		req.Credits = m[1]
+		title = strings.TrimSpace(title[:len(title)-len(m[0])])
+	}
+	req.Title = strings.TrimSpace(strings.TrimPrefix(title, "-"))
+	return req
+}
+
+// ruleList returns the <ul> of a rule: a direct child, or one wrapped in a
+// <div> as Kuali does for course lists.
+func ruleList(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.DataAtom {
+		case atom.Ul:
+			return c
+		case atom.Div:
+			if list := ruleList(c); list != nil {
+				return list
+			}
+		}
+	}
+	return nil
+}
+
+// courseLink finds a link to a catalog course, as opposed to links in free
+// text such as program pages.
+func courseLink(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			for _, attr := range c.Attr {
+				if attr.Key == "href" && strings.Contains(attr.Val, "/courses/view/") {
+					return c
+				}
+			}
+		}
+		if found := courseLink(c); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func firstElement(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			return c
+		}
+	}
+	return nil
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// plainText returns the text of an HTML fragment, such as a Kuali course
+// description, on one line.
+func plainText(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return collapse(fragment)
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(textContent(n) + " ")
+	}
+	return collapse(b.String())
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// label describes a requirement on one line, without its children.
+func (r *Requirement) label() string {
+	of := func(count int) string {
+		if count == len(r.Children) {
+			return "all of"
+		}
+		return fmt.Sprintf("%d of", count)
+	}
+	switch r.Kind {
+	case reqAllOf:
+		return "Complete all of the following:"
+	case reqNOf:
+		return fmt.Sprintf("Complete %s the following:", of(r.Count))
+	case reqConcurrent:
+		return fmt.Sprintf("Completed or concurrently enrolled in %s:", of(r.Count))
+	case reqMinGrade:
+		if r.Count == len(r.Children) {
+			return fmt.Sprintf("Earn a minimum grade of %s in each of the following:", r.Grade)
+		}
+		return fmt.Sprintf("Earn a minimum grade of %s in %d of the following:", r.Grade, r.Count)
+	case reqCourse:
+		label := r.Course
+		if r.Title != "" {
+			label += " - " + r.Title
+		}
+		if r.Credits != "" {
+			label += " (" + r.Credits + ")"
+		}
+		return label
+	}
+	if len(r.Children) > 0 {
+		return r.Text + ":"
+	}
+	return r.Text
+}
+
+// renderRequirement writes the tree as an indented outline, with courses
+// and free text items bulleted.
+func renderRequirement(w io.Writer, r *Requirement, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if len(r.Children) == 0 {
+		fmt.Fprintf(w, "%s• %s\n", indent, r.label())
+		return
+	}
+	fmt.Fprintf(w, "%s%s\n", indent, r.label())
+	for _, child := range r.Children {
+		renderRequirement(w, child, depth+1)
+	}
+}
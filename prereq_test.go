@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParsePrerequisites(t *testing.T) {
+	rule := `<div><div><span>Complete all of the following</span><ul>` +
+		`<li><span>Earn a minimum grade of <span>C+</span> in each of the following: </span><div><ul style="margin-top:5px;margin-bottom:5px">` +
+		`<li><span><a href="#/courses/view/5d1f72acd2bc1524008cb36a" target="_blank">MATH120</a> <!-- -->- <!-- -->Precalculus Mathematics<!-- --> <span style="margin-left:5px">(1.5)</span></span></li>` +
+		`</ul></div></li>` +
+		`<li><span>Complete <!-- -->1<!-- --> of: </span><div><ul>` +
+		`<li><span><a href="#/courses/view/a" target="_blank">ECON103</a> <!-- -->- <!-- -->Principles of Macroeconomics</span></li>` +
+		`<li><span><a href="#/courses/view/b" target="_blank">ECON104</a> <!-- -->- <!-- -->Principles of Microeconomics</span></li>` +
+		`</ul></div></li>` +
+		`<li><span>Completed or concurrently enrolled in <!-- -->all<!-- --> of: </span><div><ul>` +
+		`<li><span><a href="#/courses/view/c" target="_blank">STAT260</a> <!-- -->- <!-- -->Introduction to Probability and Statistics I</span></li>` +
+		`</ul></div></li>` +
+		`<li><span>minimum third-year standing; see <a href="#/programs/view/x">the program</a></span></li>` +
+		`</ul></div></div>`
+
+	req, err := parsePrerequisites(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"kind":"all_of","count":4,"children":[` +
+		`{"kind":"min_grade","count":1,"grade":"C+","children":[{"kind":"course","course":"MATH120","title":"Precalculus Mathematics","credits":"1.5"}]},` +
+		`{"kind":"n_of","count":1,"children":[{"kind":"course","course":"ECON103","title":"Principles of Macroeconomics"},{"kind":"course","course":"ECON104","title":"Principles of Microeconomics"}]},` +
+		`{"kind":"concurrent","count":1,"children":[{"kind":"course","course":"STAT260","title":"Introduction to Probability and Statistics I"}]},` +
+		`{"kind":"text","text":"minimum third-year standing; see the program"}]}`
+	if string(got) != want {
+		t.Errorf("parsed\n%s\nwant\n%s", got, want)
+	}
+
+	var out strings.Builder
+	renderRequirement(&out, req, 0)
+	wantText := `Complete all of the following:
+  Earn a minimum grade of C+ in each of the following:
+    • MATH120 - Precalculus Mathematics (1.5)
+  Complete 1 of the following:
+    • ECON103 - Principles of Macroeconomics
+    • ECON104 - Principles of Microeconomics
+  Completed or concurrently enrolled in all of:
+    • STAT260 - Introduction to Probability and Statistics I
+  • minimum third-year standing; see the program
+`
+	if out.String() != wantText {
+		t.Errorf("rendered\n%s\nwant\n%s", out.String(), wantText)
+	}
+}
+
+func TestParseEmptyPrerequisites(t *testing.T) {
+	req, err := parsePrerequisites("  ")
+	if err != nil || req != nil {
+		t.Errorf("got %v, %v for an empty rule", req, err)
+	}
+}
+
+func TestRequirementsCommand(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+
+	out := runMain(t, fake, dir, "-requirements", "MATH", "100")
+	want := `MATH 100: Calculus I
+Complete all of the following:
+  Complete 1 of the following:
+    Earn a minimum grade of B in each of the following:
+      • Pre-Calculus 12
+    • MATH120 - Precalculus Mathematics (1.5)
+  Completed or concurrently enrolled in 1 of:
+    • MATH110 - Matrix Algebra for Engineers (1.5)
+    • MATH211 - Matrix Algebra I (1.5)
+`
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	out = runMain(t, fake, dir, "-format=json", "-requirements", "CSC", "110")
+	var parsed struct {
+		Course       string
+		Requirements Requirement
+	}
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("%v:\n%s", err, out)
+	}
+	if parsed.Course != "CSC110" || parsed.Requirements.Kind != reqAllOf || parsed.Requirements.Children[0].Grade != "C+" {
+		t.Errorf("unexpected requirements: %+v", parsed)
+	}
+}
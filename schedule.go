@@ -0,0 +1,371 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ScheduledSection struct {
+	Subject  string
+	Number   string
+	Section  CourseSection
+	Meetings []MeetingTime
+}
+
+type Schedule struct {
+	Sections []ScheduledSection
+	Score    int
+}
+
+// sectionGroup holds the interchangeable sections of one schedule type in
+// one link group (e.g. the labs linked to a CSC 110 lecture).
+type sectionGroup struct {
+	ScheduleType string
+	Options      []ScheduledSection
+}
+
+// courseChoices holds a course's link groups, each split by schedule type.
+// A schedule registers in one link group and picks exactly one section from
+// each of its schedule types, so a link group without labs needs none.
+type courseChoices struct {
+	Subject    string
+	Number     string
+	LinkGroups [][]sectionGroup
+}
+
+func parseClock(t string) (int, bool) {
+	if len(t) != 4 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(t)
+	if err != nil {
+		return 0, false
+	}
+	return (n/100)*60 + n%100, true
+}
+
+func parseBannerDate(d string) (time.Time, bool) {
+	t, err := time.Parse("01/02/2006", d)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func meetingDays(mt MeetingTime) [7]bool {
+	return [7]bool{mt.Monday, mt.Tuesday, mt.Wednesday, mt.Thursday, mt.Friday, mt.Saturday, mt.Sunday}
+}
+
+func meetingsOverlap(a, b MeetingTime) bool {
+	aStart, ok1 := parseClock(a.BeginTime)
+	aEnd, ok2 := parseClock(a.EndTime)
+	bStart, ok3 := parseClock(b.BeginTime)
+	bEnd, ok4 := parseClock(b.EndTime)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		// Meetings without a time (async/online) never conflict
+		return false
+	}
+	if aStart >= bEnd || bStart >= aEnd {
+		return false
+	}
+
+	sharedDay := false
+	aDays, bDays := meetingDays(a), meetingDays(b)
+	for i := range aDays {
+		if aDays[i] && bDays[i] {
+			sharedDay = true
+			break
+		}
+	}
+	if !sharedDay {
+		return false
+	}
+
+	// Sections running in different parts of the term don't clash
+	aFrom, ok1 := parseBannerDate(a.StartDate)
+	aTo, ok2 := parseBannerDate(a.EndDate)
+	bFrom, ok3 := parseBannerDate(b.StartDate)
+	bTo, ok4 := parseBannerDate(b.EndDate)
+	if ok1 && ok2 && ok3 && ok4 && (aTo.Before(bFrom) || bTo.Before(aFrom)) {
+		return false
+	}
+	return true
+}
+
+func sectionsConflict(a, b ScheduledSection) bool {
+	for _, ma := range a.Meetings {
+		for _, mb := range b.Meetings {
+			if meetingsOverlap(ma, mb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sectionMeetings(ctx context.Context, session *Session, term string, section CourseSection) ([]MeetingTime, error) {
+	details, err := sectionDetails(ctx, session, term, section)
+	if err != nil {
+		return nil, err
+	}
+
+	var meetings []MeetingTime
+	for _, mf := range details {
+		meetings = append(meetings, mf.MeetingTime)
+	}
+	return meetings, nil
+}
+
+// groupSections splits a course's sections by link group, then by schedule
+// type, preserving the order in which Banner returned them.
+func groupSections(subject, number string, sections []ScheduledSection) courseChoices {
+	choices := courseChoices{Subject: subject, Number: number}
+	linkIndex := make(map[string]int)
+	var typeIndex []map[string]int
+	for _, s := range sections {
+		key := linkKey(s.Section)
+		l, ok := linkIndex[key]
+		if !ok {
+			l = len(choices.LinkGroups)
+			linkIndex[key] = l
+			choices.LinkGroups = append(choices.LinkGroups, nil)
+			typeIndex = append(typeIndex, make(map[string]int))
+		}
+		scheduleType := s.Section.ScheduleTypeDescription
+		i, ok := typeIndex[l][scheduleType]
+		if !ok {
+			i = len(choices.LinkGroups[l])
+			typeIndex[l][scheduleType] = i
+			choices.LinkGroups[l] = append(choices.LinkGroups[l], sectionGroup{ScheduleType: scheduleType})
+		}
+		choices.LinkGroups[l][i].Options = append(choices.LinkGroups[l][i].Options, s)
+	}
+	return choices
+}
+
+// rankedSchedule is a schedule and the order it was found in, which breaks
+// ties between equal scores.
+type rankedSchedule struct {
+	Schedule
+	found int
+}
+
+// worstFirst is a min-heap of the best schedules found so far, with the
+// one to evict at the root.
+type worstFirst []rankedSchedule
+
+func (h worstFirst) Len() int { return len(h) }
+func (h worstFirst) Less(i, j int) bool {
+	if h[i].Score != h[j].Score {
+		return h[i].Score < h[j].Score
+	}
+	return h[i].found > h[j].found
+}
+func (h worstFirst) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *worstFirst) Push(x interface{}) { *h = append(*h, x.(rankedSchedule)) }
+func (h *worstFirst) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// enumerateSchedules scores every conflict-free schedule as it is found and
+// returns the best keep of them, best first, with how many were found.
+func enumerateSchedules(ctx context.Context, courses []courseChoices, keep int) ([]Schedule, int, error) {
+	best := &worstFirst{}
+	found := 0
+	chosen := make([]ScheduledSection, 0, len(courses))
+
+	consider := func() {
+		score := scoreSchedule(Schedule{Sections: chosen})
+		if keep <= 0 || (best.Len() == keep && score <= (*best)[0].Score) {
+			return
+		}
+		picked := make([]ScheduledSection, len(chosen))
+		copy(picked, chosen)
+		heap.Push(best, rankedSchedule{Schedule{Sections: picked, Score: score}, found})
+		if best.Len() > keep {
+			heap.Pop(best)
+		}
+	}
+
+	var pickCourse func(course int)
+	var pickSection func(course int, groups []sectionGroup, group int)
+	pickCourse = func(course int) {
+		if ctx.Err() != nil {
+			return
+		}
+		if course == len(courses) {
+			consider()
+			found++
+			return
+		}
+		for _, groups := range courses[course].LinkGroups {
+			pickSection(course, groups, 0)
+		}
+	}
+	pickSection = func(course int, groups []sectionGroup, group int) {
+		if group == len(groups) {
+			pickCourse(course + 1)
+			return
+		}
+		for _, option := range groups[group].Options {
+			conflict := false
+			for _, c := range chosen {
+				if sectionsConflict(option, c) {
+					conflict = true
+					break
+				}
+			}
+			if conflict {
+				continue
+			}
+			chosen = append(chosen, option)
+			pickSection(course, groups, group+1)
+			chosen = chosen[:len(chosen)-1]
+		}
+	}
+	pickCourse(0)
+	if err := ctx.Err(); err != nil {
+		return nil, found, err
+	}
+
+	schedules := make([]Schedule, best.Len())
+	for i := len(schedules) - 1; i >= 0; i-- {
+		schedules[i] = heap.Pop(best).(rankedSchedule).Schedule
+	}
+	return schedules, found, nil
+}
+
+// scoreSchedule rewards days off and penalizes early starts, late finishes,
+// long gaps between classes and sections that are already full.
+func scoreSchedule(s Schedule) int {
+	type interval struct{ start, end int }
+	var byDay [7][]interval
+	score := 0
+
+	for _, sec := range s.Sections {
+		if !sec.Section.OpenSection && sec.Section.SeatsAvailable <= 0 {
+			score -= 20
+		}
+		for _, mt := range sec.Meetings {
+			start, ok1 := parseClock(mt.BeginTime)
+			end, ok2 := parseClock(mt.EndTime)
+			if !ok1 || !ok2 {
+				continue
+			}
+			days := meetingDays(mt)
+			for d := range days {
+				if !days[d] {
+					continue
+				}
+				byDay[d] = append(byDay[d], interval{start, end})
+				if start < 9*60 {
+					score -= 5
+				}
+				if end > 17*60 {
+					score -= 3
+				}
+			}
+		}
+	}
+
+	for d := 0; d < 5; d++ {
+		if len(byDay[d]) == 0 {
+			score += 15
+			continue
+		}
+		sort.Slice(byDay[d], func(i, j int) bool { return byDay[d][i].start < byDay[d][j].start })
+		for i := 1; i < len(byDay[d]); i++ {
+			if gap := byDay[d][i].start - byDay[d][i-1].end; gap > 60 {
+				score -= gap / 60
+			}
+		}
+	}
+	return score
+}
+
+func generateSchedules(ctx context.Context, session *Session, term string, courseQueries [][]string, maxSchedules int) error {
+	var courses []courseChoices
+	for _, query := range courseQueries {
+		subject, number := query[0], query[1]
+		fmt.Printf("Fetching sections for %s %s...\n", subject, number)
+
+		response, err := session.fetchCourseInfo(ctx, term, subject, number)
+		if err != nil {
+			return fmt.Errorf("error fetching sections for %s %s: %v", subject, number, err)
+		}
+
+		var sections []ScheduledSection
+		for _, section := range response.Data {
+			if section.CourseReferenceNumber == "" {
+				continue
+			}
+			meetings, err := sectionMeetings(ctx, session, term, section)
+			if err != nil {
+				return fmt.Errorf("error fetching meeting times for CRN %s: %v", section.CourseReferenceNumber, err)
+			}
+			sections = append(sections, ScheduledSection{
+				Subject:  subject,
+				Number:   number,
+				Section:  section,
+				Meetings: meetings,
+			})
+		}
+		if len(sections) == 0 {
+			return fmt.Errorf("%s %s is not offered in term %s", subject, number, term)
+		}
+		courses = append(courses, groupSections(subject, number, sections))
+	}
+
+	schedules, found, err := enumerateSchedules(ctx, courses, maxSchedules)
+	if err != nil {
+		return err
+	}
+	if found == 0 {
+		fmt.Println("\nNo conflict-free schedules found.")
+		return nil
+	}
+
+	fmt.Printf("\nFound %d conflict-free schedules\n", found)
+	for i, schedule := range schedules {
+		printSchedule(i+1, schedule)
+	}
+	return nil
+}
+
+func printSchedule(rank int, s Schedule) {
+	title := fmt.Sprintf("Schedule %d (score %d)", rank, s.Score)
+	fmt.Printf("\n%s\n%s\n", title, strings.Repeat("=", len(title)))
+
+	for _, sec := range s.Sections {
+		status := ""
+		if !sec.Section.OpenSection && sec.Section.SeatsAvailable <= 0 {
+			status = " [FULL]"
+		}
+		fmt.Printf("%s %s %s (CRN: %s) %s%s\n",
+			sec.Subject, sec.Number, sec.Section.Section, sec.Section.CourseReferenceNumber,
+			sec.Section.ScheduleTypeDescription, status)
+
+		if len(sec.Meetings) == 0 {
+			fmt.Println("    No scheduled meeting times")
+		}
+		for _, mt := range sec.Meetings {
+			if mt.BeginTime == "" {
+				fmt.Println("    No scheduled meeting time")
+				continue
+			}
+			location := ""
+			if mt.Building != "" {
+				location = fmt.Sprintf(", %s %s", mt.Building, mt.Room)
+			}
+			fmt.Printf("    %s-%s %s%s\n", formatTime(mt.BeginTime), formatTime(mt.EndTime), getDays(mt), location)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// scheduled is a section meeting on Mondays from begin to end.
+func scheduled(crn, scheduleType, link, begin, end string) ScheduledSection {
+	return ScheduledSection{
+		Subject: "CSC",
+		Number:  "110",
+		Section: CourseSection{
+			Subject:                 "CSC",
+			CourseNumber:            "110",
+			CourseReferenceNumber:   crn,
+			ScheduleTypeDescription: scheduleType,
+			LinkIdentifier:          link,
+			IsSectionLinked:         link != "",
+			OpenSection:             true,
+		},
+		Meetings: []MeetingTime{{BeginTime: begin, EndTime: end, Monday: true}},
+	}
+}
+
+func crns(s Schedule) string {
+	var out string
+	for i, sec := range s.Sections {
+		if i > 0 {
+			out += ","
+		}
+		out += sec.Section.CourseReferenceNumber
+	}
+	return out
+}
+
+func TestScheduleGroupsFollowLinkGroups(t *testing.T) {
+	// L2's lecture has no lab, so it is a schedule on its own
+	course := groupSections("CSC", "110", []ScheduledSection{
+		scheduled("1", "Lecture", "L1", "1000", "1100"),
+		scheduled("2", "Lecture", "L2", "1200", "1300"),
+		scheduled("3", "Lab", "L1", "1400", "1500"),
+		scheduled("4", "Lab", "L1", "1500", "1600"),
+	})
+	if len(course.LinkGroups) != 2 || len(course.LinkGroups[0]) != 2 || len(course.LinkGroups[1]) != 1 {
+		t.Fatalf("link groups = %+v", course.LinkGroups)
+	}
+
+	schedules, found, err := enumerateSchedules(context.Background(), []courseChoices{course}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, s := range schedules {
+		got[crns(s)] = true
+	}
+	if found != 3 || !got["1,3"] || !got["1,4"] || !got["2"] {
+		t.Errorf("found %d schedules %v, want 1,3 1,4 and 2", found, got)
+	}
+}
+
+func TestEnumerateSchedulesKeepsTheBestOverall(t *testing.T) {
+	// Later lectures score better (no early start), and DFS finds them last
+	var sections []ScheduledSection
+	for i, begin := range []string{"0700", "0730", "0800", "0830", "1000", "1100"} {
+		end := begin[:2] + "50"
+		sections = append(sections, scheduled(string(rune('a'+i)), "Lecture", "", begin, end))
+	}
+	schedules, found, err := enumerateSchedules(context.Background(), []courseChoices{groupSections("CSC", "110", sections)}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != 6 || len(schedules) != 2 {
+		t.Fatalf("found %d, kept %d, want 6 and 2", found, len(schedules))
+	}
+	// Equal scores keep the order they were found in
+	if crns(schedules[0]) != "e" || crns(schedules[1]) != "f" || schedules[0].Score < schedules[1].Score {
+		t.Errorf("best schedules = %s (%d), %s (%d), want e then f",
+			crns(schedules[0]), schedules[0].Score, crns(schedules[1]), schedules[1].Score)
+	}
+	for _, s := range schedules {
+		if s.Score != scoreSchedule(s) {
+			t.Errorf("schedule %s scored %d, want %d", crns(s), s.Score, scoreSchedule(s))
+		}
+	}
+}
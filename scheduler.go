@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SchedulerConfig controls how fast and how hard we hit Banner and Kuali.
+// Limits apply to each host separately.
+type SchedulerConfig struct {
+	Rate            float64       // sustained requests per second
+	Burst           int           // requests allowed back to back before the rate applies
+	HostConcurrency int           // requests in flight at once
+	MaxRetries      int           // retries after the first attempt
+	Timeout         time.Duration // bound on each attempt, including reading the body
+	BaseBackoff     time.Duration
+	MaxBackoff      time.Duration
+}
+
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		Rate:            5,
+		Burst:           10,
+		HostConcurrency: 4,
+		MaxRetries:      4,
+		Timeout:         30 * time.Second,
+		BaseBackoff:     500 * time.Millisecond,
+		MaxBackoff:      30 * time.Second,
+	}
+}
+
+// sharedTransport is the HTTP layer every Banner and Kuali client goes
+// through, so limits hold across sessions.
+var sharedTransport http.RoundTripper = NewScheduler(http.DefaultTransport, DefaultSchedulerConfig())
+
+// tokenBucket is an adaptive token bucket: throttling responses halve its
+// rate and each success creeps it back towards the configured ceiling.
+type tokenBucket struct {
+	mu      sync.Mutex
+	rate    float64
+	maxRate float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, maxRate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// refill adds the tokens earned since the last refill. Two calls can see the
+// same clock reading, so nothing is added for no elapsed time.
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+	b.last = now
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		b.refill(time.Now())
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *tokenBucket) throttled() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	b.rate = math.Max(b.rate/2, b.maxRate/32)
+	b.tokens = 0
+}
+
+func (b *tokenBucket) succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = math.Min(b.maxRate, b.rate+b.maxRate/20)
+}
+
+// hostLimits has no bucket when the rate is unlimited.
+type hostLimits struct {
+	bucket *tokenBucket
+	slots  chan struct{}
+}
+
+// Scheduler rate limits, bounds concurrency and retries requests per host.
+// Connection failures and 429/502/503/504 responses are retried with
+// exponential backoff and jitter, honouring Retry-After when present.
+type Scheduler struct {
+	next   http.RoundTripper
+	config SchedulerConfig
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimits
+}
+
+func NewScheduler(next http.RoundTripper, config SchedulerConfig) *Scheduler {
+	if config.HostConcurrency < 1 {
+		config.HostConcurrency = 1
+	}
+	if config.Rate <= 0 {
+		config.Rate = math.Inf(1)
+	}
+	return &Scheduler{next: next, config: config, hosts: make(map[string]*hostLimits)}
+}
+
+func (s *Scheduler) limits(host string) *hostLimits {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostLimits{slots: make(chan struct{}, s.config.HostConcurrency)}
+		if !math.IsInf(s.config.Rate, 1) {
+			h.bucket = newTokenBucket(s.config.Rate, s.config.Burst)
+		}
+		s.hosts[host] = h
+	}
+	return h
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoffDelay returns a full-jitter exponential delay for the given retry.
+func backoffDelay(attempt int, base, max time.Duration) time.Duration {
+	ceiling := float64(base) * math.Pow(2, float64(attempt))
+	if ceiling > float64(max) {
+		ceiling = float64(max)
+	}
+	return time.Duration(rand.Float64() * ceiling)
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date.
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func (s *Scheduler) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	h := s.limits(req.URL.Host)
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("cannot retry %s %s: request body is not replayable", req.Method, req.URL)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
+		if h.bucket != nil {
+			if err := h.bucket.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		select {
+		case h.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if s.config.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		}
+		resp, err := s.next.RoundTrip(req.WithContext(attemptCtx))
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			if h.bucket != nil {
+				h.bucket.succeeded()
+			}
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() {
+				cancel()
+				<-h.slots
+			}}
+			return resp, nil
+		}
+		<-h.slots
+
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			cancel()
+			return nil, ctx.Err()
+		}
+		if attempt >= s.config.MaxRetries {
+			if resp != nil {
+				resp.Body = &releasingBody{ReadCloser: resp.Body, release: cancel}
+			} else {
+				cancel()
+			}
+			return resp, err
+		}
+
+		delay := backoffDelay(attempt, s.config.BaseBackoff, s.config.MaxBackoff)
+		if resp != nil {
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				if h.bucket != nil {
+					h.bucket.throttled()
+				}
+				if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+					delay = min(d, s.config.MaxBackoff)
+				}
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// releasingBody frees the host's concurrency slot once the caller is done
+// reading the response.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	for attempt := 0; attempt < 8; attempt++ {
+		ceiling := min(base<<attempt, max)
+		for i := 0; i < 100; i++ {
+			if d := backoffDelay(attempt, base, max); d < 0 || d > ceiling {
+				t.Fatalf("backoffDelay(%d) = %v, want within [0, %v]", attempt, d, ceiling)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"0", 0, true},
+		{"-5", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.header, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTokenBucketRefillWithoutElapsedTime(t *testing.T) {
+	b := newTokenBucket(math.Inf(1), 1)
+	b.refill(b.last)
+	if math.IsNaN(b.tokens) || b.tokens != 1 {
+		t.Errorf("tokens = %v after a refill with no elapsed time", b.tokens)
+	}
+}
+
+// testScheduler retries quickly so tests don't sleep through real backoff.
+func testScheduler(rate float64, concurrency, retries int) *Scheduler {
+	return NewScheduler(http.DefaultTransport, SchedulerConfig{
+		Rate:            rate,
+		Burst:           1,
+		HostConcurrency: concurrency,
+		MaxRetries:      retries,
+		Timeout:         5 * time.Second,
+		BaseBackoff:     time.Millisecond,
+		MaxBackoff:      10 * time.Millisecond,
+	})
+}
+
+func TestSchedulerRetriesThrottledRequests(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch hits.Add(1) {
+		case 1:
+			// Far longer than MaxBackoff, which caps it
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	s := testScheduler(1000, 1, 4)
+	client := &http.Client{Transport: s}
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hits.Load() != 3 {
+		t.Errorf("status %d after %d requests, want 200 after 3", resp.StatusCode, hits.Load())
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Retry-After wasn't capped: took %v", time.Since(start))
+	}
+	for _, h := range s.hosts {
+		if h.bucket.rate >= h.bucket.maxRate {
+			t.Errorf("rate %v wasn't lowered after throttling", h.bucket.rate)
+		}
+	}
+}
+
+func TestSchedulerGivesUpAfterMaxRetries(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: testScheduler(0, 1, 2)}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || hits.Load() != 3 {
+		t.Errorf("status %d after %d requests, want 503 after 3", resp.StatusCode, hits.Load())
+	}
+
+	hits.Store(0)
+	resp, err = client.Get(srv.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if hits.Load() != 1 {
+		t.Errorf("a 404 was requested %d times, want once", hits.Load())
+	}
+}
+
+func TestSchedulerLimitsConcurrencyPerHost(t *testing.T) {
+	limited := func(peak *atomic.Int32) *httptest.Server {
+		var inFlight atomic.Int32
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		}))
+	}
+	var peakA, peakB atomic.Int32
+	a, b := limited(&peakA), limited(&peakB)
+	defer a.Close()
+	defer b.Close()
+
+	// Unlimited rate, so only the concurrency limit holds requests back
+	client := &http.Client{Transport: testScheduler(0, 2, 0)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for _, url := range []string{a.URL, b.URL} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req, _ := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+			}()
+		}
+	}
+	wg.Wait()
+	if peakA.Load() != 2 || peakB.Load() != 2 {
+		t.Errorf("peak requests in flight = %d and %d, want 2 to each host", peakA.Load(), peakB.Load())
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fields of a course that search looks in, with how much a match in each
+// counts towards a course's score.
+const (
+	fieldDescription = iota
+	fieldSubject
+	fieldTitle
+	fieldID
+)
+
+var fieldWeights = [...]float64{fieldDescription: 1, fieldSubject: 2, fieldTitle: 4, fieldID: 8}
+
+// searchIndex is an inverted index from words to the courses they appear in.
+type searchIndex struct {
+	courses      []Course
+	descriptions []string
+	// postings maps a word to the courses containing it and the weight of
+	// the best field it appears in
+	postings map[string]map[int]float64
+	words    []string
+}
+
+// searchWords splits text into lowercase words of letters and digits.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// buildSearchIndex indexes course ids, titles, subject descriptions and
+// the descriptions cached from Kuali, keyed by course id.
+func buildSearchIndex(courses []Course, descriptions map[string]string) *searchIndex {
+	idx := &searchIndex{courses: courses, postings: make(map[string]map[int]float64)}
+	add := func(doc, field int, text string) {
+		for _, word := range searchWords(text) {
+			docs := idx.postings[word]
+			if docs == nil {
+				docs = make(map[int]float64)
+				idx.postings[word] = docs
+			}
+			docs[doc] = max(docs[doc], fieldWeights[field])
+		}
+	}
+	for i, c := range courses {
+		description := descriptions[c.CourseID]
+		idx.descriptions = append(idx.descriptions, description)
+		// "CSC110" is found by csc110 as well as by "csc 110"
+		add(i, fieldID, c.CourseID+" "+c.SubjectCode.Name+" "+catalogNumber(c))
+		add(i, fieldTitle, c.Title)
+		add(i, fieldSubject, c.SubjectCode.Description)
+		add(i, fieldDescription, description)
+	}
+	for word := range idx.postings {
+		idx.words = append(idx.words, word)
+	}
+	sort.Strings(idx.words)
+	return idx
+}
+
+// loadSearchIndex builds the index from courses.json and whatever
+// descriptions the prerequisite cache holds, without touching the network.
+func loadSearchIndex(coursesPath, cachePath string) (*searchIndex, error) {
+	courses, err := loadCoursesFromJSON(coursesPath)
+	if err != nil {
+		return nil, err
+	}
+	descriptions := make(map[string]string)
+	cache, err := readPrereqCache(cachePath)
+	switch {
+	case err == nil:
+		for id, c := range cache.Courses {
+			descriptions[id] = c.Description
+		}
+	case !os.IsNotExist(err):
+		fmt.Fprintf(progress, "Warning: searching without descriptions: %v\n", err)
+	}
+	return buildSearchIndex(courses, descriptions), nil
+}
+
+// editDistance returns the Levenshtein distance between a and b, or limit+1
+// once it is known to exceed limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > limit || -diff > limit {
+		return limit + 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			best = min(best, cur[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// matches returns the indexed words a query word matches and how closely:
+// exactly, as a prefix of a longer word, or within a typo or two. Words
+// with digits are course numbers, where a typo is a different course.
+func (idx *searchIndex) matches(query string) map[string]float64 {
+	found := make(map[string]float64)
+	if _, ok := idx.postings[query]; ok {
+		found[query] = 1
+	}
+	if len(query) >= 2 {
+		start := sort.SearchStrings(idx.words, query)
+		for _, word := range idx.words[start:] {
+			if !strings.HasPrefix(word, query) {
+				break
+			}
+			if word != query {
+				found[word] = 0.7
+			}
+		}
+	}
+	if n := len([]rune(query)); n >= 4 && !strings.ContainsAny(query, "0123456789") {
+		limit := 1
+		if n >= 8 {
+			limit = 2
+		}
+		for _, word := range idx.words {
+			if _, ok := found[word]; ok {
+				continue
+			}
+			if d := editDistance(query, word, limit); d <= limit {
+				found[word] = 0.5 / float64(d)
+			}
+		}
+	}
+	return found
+}
+
+// searchQuery is a parsed query: words every result must match, and the
+// subject and level filters given as subject:CSC and level:300.
+type searchQuery struct {
+	words    []string
+	subjects map[string]bool
+	levels   map[byte]bool
+}
+
+func parseSearchQuery(query string) (searchQuery, error) {
+	q := searchQuery{subjects: make(map[string]bool), levels: make(map[byte]bool)}
+	for _, field := range strings.Fields(query) {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			q.words = append(q.words, searchWords(field)...)
+			continue
+		}
+		for _, v := range strings.Split(value, ",") {
+			if v == "" {
+				continue
+			}
+			switch strings.ToLower(key) {
+			case "subject":
+				q.subjects[strings.ToUpper(v)] = true
+			case "level":
+				if v[0] < '0' || v[0] > '9' {
+					return q, fmt.Errorf("level %q should be a number such as 300", v)
+				}
+				q.levels[v[0]] = true
+			default:
+				return q, fmt.Errorf("unknown filter %q (use subject: or level:)", key)
+			}
+		}
+	}
+	return q, nil
+}
+
+func (q searchQuery) allows(c Course) bool {
+	if len(q.subjects) > 0 && !q.subjects[c.SubjectCode.Name] {
+		return false
+	}
+	number := catalogNumber(c)
+	return len(q.levels) == 0 || (number != "" && q.levels[number[0]])
+}
+
+// SearchResult is a course matching a search.
+type SearchResult struct {
+	Course             string  `json:"course"`
+	Subject            string  `json:"subject"`
+	Number             string  `json:"number"`
+	Title              string  `json:"title"`
+	SubjectDescription string  `json:"subject_description"`
+	Description        string  `json:"description,omitempty"`
+	Score              float64 `json:"score"`
+}
+
+// search ranks the courses matching every word of the query by how well
+// and where each word matched, rarer words counting for more. A query of
+// only filters lists the matching courses in course order.
+func (idx *searchIndex) search(query string, limit int) ([]SearchResult, error) {
+	q, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var scores map[int]float64
+	for _, word := range q.words {
+		wordScores := make(map[int]float64)
+		for match, closeness := range idx.matches(word) {
+			docs := idx.postings[match]
+			idf := math.Log(1 + float64(len(idx.courses))/float64(len(docs)))
+			for doc, weight := range docs {
+				wordScores[doc] = max(wordScores[doc], closeness*weight*idf)
+			}
+		}
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		for doc := range scores {
+			if s, ok := wordScores[doc]; ok {
+				scores[doc] += s
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	var results []SearchResult
+	for i, c := range idx.courses {
+		score, ok := scores[i]
+		if (len(q.words) > 0 && !ok) || !q.allows(c) {
+			continue
+		}
+		results = append(results, SearchResult{
+			Course:             c.CourseID,
+			Subject:            c.SubjectCode.Name,
+			Number:             catalogNumber(c),
+			Title:              c.Title,
+			SubjectDescription: c.SubjectCode.Description,
+			Description:        idx.descriptions[i],
+			Score:              math.Round(score*100) / 100,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Course < results[j].Course
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// writeSearchResults writes one course per line, or a JSON array.
+func writeSearchResults(w io.Writer, results []SearchResult, format string) error {
+	switch format {
+	case formatJSON:
+		if results == nil {
+			results = []SearchResult{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case formatText:
+	default:
+		return fmt.Errorf("-search writes text or json, not %s", format)
+	}
+
+	var b strings.Builder
+	for _, r := range results {
+		fmt.Fprintf(&b, "%-9s %s\n", r.Course, r.Title)
+	}
+	if len(results) == 0 {
+		b.WriteString("No courses found\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	courses, err := loadCoursesFromJSON(filepath.Join("testdata", "courses.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := buildSearchIndex(courses, map[string]string{
+		"CSC110": "Introduction to designing, implementing and understanding computer programs.",
+	})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"csc 110", []string{"CSC110"}},
+		{"CSC111", []string{"CSC111"}},
+		{"programing", []string{"CSC110", "CSC111"}},
+		{"calc", []string{"MATH100"}},
+		{"computer programs", []string{"CSC110"}},
+		{"programming engineering", []string{"CSC111"}},
+		{"level:100 subject:math", []string{"MATH100"}},
+		{"fundamentals subject:MATH", nil},
+	}
+	for _, tt := range tests {
+		results, err := idx.search(tt.query, 0)
+		if err != nil {
+			t.Errorf("search(%q): %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Course)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("search(%q) = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("search(%q) = %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+
+	if _, err := idx.search("year:2", 0); err == nil {
+		t.Error("search with unknown filter succeeded")
+	}
+}
+
+func TestSearchOffline(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+
+	// Fill the description cache, then search without the network
+	runMain(t, fake, dir, "-unlocks", "MATH", "120")
+	fake.Close()
+	runMain(t, fake, dir, "-search", "-format=json", "-o", "results.json", "designing", "level:1")
+
+	data, err := os.ReadFile(filepath.Join(dir, "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []SearchResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatalf("%v:\n%s", err, data)
+	}
+	if len(results) != 1 || results[0].Course != "CSC110" || results[0].Description == "" {
+		t.Errorf("results = %+v", results)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiServer answers the -serve REST API from the newest snapshot of each
+// term, courses.json and the prerequisite cache. With refresh, sections
+// and course details missing from those are fetched from Banner and Kuali
+// and kept in memory for ttl, or missTTL when Banner had nothing either.
+type apiServer struct {
+	store   snapshotStore
+	term    string
+	catalog []Course
+	details func(ctx context.Context, id string) (graphCourse, bool, error)
+	refresh bool
+	ttl     time.Duration
+	missTTL time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	loaded   map[string]snapshotRows
+	fetched  map[string]fetchedRows // term/subject/number or term/crn
+	sessions map[string]*Session    // one per term, so terms don't rebind each other's
+}
+
+// fetchedRows is what a refresh found, possibly nothing.
+type fetchedRows struct {
+	rows    []CSVExportRow
+	expires time.Time
+}
+
+// snapshotRows is a term's newest snapshot, reloaded once a crawl saves a
+// newer one.
+type snapshotRows struct {
+	id   string
+	rows []CSVExportRow
+}
+
+func newAPIServer(store snapshotStore, term string, catalog []Course) *apiServer {
+	return &apiServer{
+		store:    store,
+		term:     term,
+		catalog:  catalog,
+		details:  func(ctx context.Context, id string) (graphCourse, bool, error) { return graphCourse{}, false, nil },
+		ttl:      15 * time.Minute,
+		missTTL:  time.Minute,
+		now:      time.Now,
+		loaded:   make(map[string]snapshotRows),
+		fetched:  make(map[string]fetchedRows),
+		sessions: make(map[string]*Session),
+	}
+}
+
+// session returns the Banner session used for term.
+func (s *apiServer) session(term string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[term]; ok {
+		return session, nil
+	}
+	session, err := NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("error creating session: %v", err)
+	}
+	s.sessions[term] = session
+	return session, nil
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /terms", s.handleTerms)
+	mux.HandleFunc("GET /courses", s.handleCourses)
+	mux.HandleFunc("GET /courses/{subject}/{number}", s.handleCourse)
+	mux.HandleFunc("GET /sections/{term}/{crn}", s.handleSection)
+	mux.HandleFunc("GET /instructors/{name}", s.handleInstructor)
+	return mux
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, apiError{Error: fmt.Sprintf(format, args...)})
+}
+
+// termRows returns the rows of term's newest snapshot, or none if the term
+// was never crawled.
+func (s *apiServer) termRows(term string) ([]CSVExportRow, error) {
+	snapshots, err := s.store.list(term)
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	latest := snapshots[len(snapshots)-1]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached, ok := s.loaded[term]; ok && cached.id == latest.ID {
+		return cached.rows, nil
+	}
+	rows, err := s.store.load(latest)
+	if err != nil {
+		return nil, err
+	}
+	s.loaded[term] = snapshotRows{id: latest.ID, rows: rows}
+	return rows, nil
+}
+
+// sections returns the rows of term that match, fetching them with fetch
+// under key if the snapshot has none and the server refreshes on a miss.
+func (s *apiServer) sections(ctx context.Context, term, key string, match func(CSVExportRow) bool, fetch func(context.Context, *Session) ([]CSVExportRow, error)) ([]CourseOutput, error) {
+	rows, err := s.termRows(term)
+	if err != nil {
+		return nil, err
+	}
+	var found []CSVExportRow
+	for _, row := range rows {
+		if row.CRN != "" && match(row) {
+			found = append(found, row)
+		}
+	}
+
+	if len(found) == 0 && s.refresh && fetch != nil {
+		key = term + "/" + key
+		s.mu.Lock()
+		cached, ok := s.fetched[key]
+		s.mu.Unlock()
+		// Misses expire sooner, so a section added since is found soon after
+		if !ok || !s.now().Before(cached.expires) {
+			session, err := s.session(term)
+			if err != nil {
+				return nil, err
+			}
+			rows, err := fetch(ctx, session)
+			if err != nil {
+				return nil, err
+			}
+			ttl := s.ttl
+			if len(rows) == 0 {
+				ttl = min(s.missTTL, s.ttl)
+			}
+			now := s.now()
+			cached = fetchedRows{rows: rows, expires: now.Add(ttl)}
+			s.mu.Lock()
+			// Drop expired entries so misses for made-up CRNs don't pile up
+			for k, f := range s.fetched {
+				if !now.Before(f.expires) {
+					delete(s.fetched, k)
+				}
+			}
+			s.fetched[key] = cached
+			s.mu.Unlock()
+		}
+		found = cached.rows
+	}
+
+	outputs := []CourseOutput{}
+	for _, row := range found {
+		outputs = append(outputs, toCourseOutput(row))
+	}
+	return outputs, nil
+}
+
+// requestTerm is the term query parameter, or the -semester term.
+func (s *apiServer) requestTerm(r *http.Request) (string, error) {
+	term := r.URL.Query().Get("term")
+	if term == "" {
+		return s.term, nil
+	}
+	if !termCodePattern.MatchString(term) {
+		return "", fmt.Errorf("term %q should be a term code such as 202509", term)
+	}
+	return term, nil
+}
+
+// apiTerm is a term the cache has sections of.
+type apiTerm struct {
+	Code        string    `json:"code"`
+	Description string    `json:"description"`
+	Snapshot    string    `json:"snapshot"`
+	Crawled     time.Time `json:"crawled"`
+	Sections    int       `json:"sections"`
+	Default     bool      `json:"default,omitempty"`
+}
+
+func (s *apiServer) handleTerms(w http.ResponseWriter, r *http.Request) {
+	codes, err := s.store.terms()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	terms := []apiTerm{}
+	for _, code := range codes {
+		snapshots, err := s.store.list(code)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		if len(snapshots) == 0 {
+			continue
+		}
+		latest := snapshots[len(snapshots)-1]
+		terms = append(terms, apiTerm{
+			Code:        code,
+			Description: termName(code),
+			Snapshot:    latest.ID,
+			Crawled:     latest.Taken,
+			Sections:    latest.Sections,
+			Default:     code == s.term,
+		})
+	}
+	sort.Slice(terms, func(i, j int) bool { return terms[i].Code > terms[j].Code })
+	writeJSON(w, http.StatusOK, terms)
+}
+
+// apiCourse is a catalog course.
+type apiCourse struct {
+	ID           string `json:"id"`
+	Subject      string `json:"subject"`
+	CourseNumber string `json:"course_number"`
+	Title        string `json:"title"`
+}
+
+func newAPICourse(c Course) apiCourse {
+	return apiCourse{ID: c.CourseID, Subject: c.SubjectCode.Name, CourseNumber: catalogNumber(c), Title: c.Title}
+}
+
+// handleCourses lists catalog courses, filtered like -search by
+// comma-separated subjects and levels.
+func (s *apiServer) handleCourses(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q, err := parseSearchQuery("subject:" + params.Get("subject") + " level:" + params.Get("level"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	courses := []apiCourse{}
+	for _, c := range s.catalog {
+		if q.allows(c) {
+			courses = append(courses, newAPICourse(c))
+		}
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i].ID < courses[j].ID })
+	writeJSON(w, http.StatusOK, courses)
+}
+
+// apiCourseDetail is a course's Kuali details and its sections in a term.
+type apiCourseDetail struct {
+	apiCourse
+	Term         string         `json:"term"`
+	Description  string         `json:"description,omitempty"`
+	Requirements *Requirement   `json:"requirements,omitempty"`
+	Sections     []CourseOutput `json:"sections"`
+}
+
+func (s *apiServer) handleCourse(w http.ResponseWriter, r *http.Request) {
+	subject, number := strings.ToUpper(r.PathValue("subject")), strings.ToUpper(r.PathValue("number"))
+	term, err := s.requestTerm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	var course *Course
+	for i, c := range s.catalog {
+		if c.CourseID == subject+number {
+			course = &s.catalog[i]
+			break
+		}
+	}
+	if course == nil {
+		writeError(w, http.StatusNotFound, "course %s %s not found in courses.json", subject, number)
+		return
+	}
+
+	detail := apiCourseDetail{apiCourse: newAPICourse(*course), Term: term}
+	info, ok, err := s.details(r.Context(), course.CourseID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+	if ok {
+		detail.Description, detail.Requirements = info.Description, info.Requirements
+	}
+	detail.Sections, err = s.sections(r.Context(), term, subject+"/"+number,
+		func(row CSVExportRow) bool { return row.Subject == subject && row.CourseNumber == number },
+		func(ctx context.Context, session *Session) ([]CSVExportRow, error) {
+			return fetchCourseRows(ctx, session, term, subject, number)
+		})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// handleSection returns a section's rows, one per meeting.
+func (s *apiServer) handleSection(w http.ResponseWriter, r *http.Request) {
+	term, crn := r.PathValue("term"), r.PathValue("crn")
+	if !termCodePattern.MatchString(term) {
+		writeError(w, http.StatusBadRequest, "term %q should be a term code such as 202509", term)
+		return
+	}
+	sections, err := s.sections(r.Context(), term, crn,
+		func(row CSVExportRow) bool { return row.CRN == crn },
+		func(ctx context.Context, session *Session) ([]CSVExportRow, error) {
+			section, err := session.findSection(ctx, term, crn)
+			var missing *sectionNotFoundError
+			if errors.As(err, &missing) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			details, err := sectionDetails(ctx, session, term, *section)
+			if err != nil {
+				return nil, err
+			}
+			return sectionRows(term, "", *section, details), nil
+		})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+	if len(sections) == 0 {
+		writeError(w, http.StatusNotFound, "CRN %s not found in %s", crn, termName(term))
+		return
+	}
+	writeJSON(w, http.StatusOK, sections)
+}
+
+// handleInstructor returns the sections in a term whose instructors' names
+// contain every word of the given one, ignoring case and order, so "Jane
+// Doe" finds Banner's "Doe, Jane". Banner can't search by instructor, so
+// this only reads the cache.
+func (s *apiServer) handleInstructor(w http.ResponseWriter, r *http.Request) {
+	words := strings.FieldsFunc(strings.ToLower(r.PathValue("name")), func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	term, err := s.requestTerm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	sections, err := s.sections(r.Context(), term, "",
+		func(row CSVExportRow) bool {
+			names := strings.ToLower(instructorNames(row))
+			for _, word := range words {
+				if !strings.Contains(names, word) {
+					return false
+				}
+			}
+			return len(words) > 0
+		}, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if len(sections) == 0 {
+		writeError(w, http.StatusNotFound, "no sections taught by %q in %s", r.PathValue("name"), termName(term))
+		return
+	}
+	writeJSON(w, http.StatusOK, sections)
+}
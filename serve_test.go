@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// getAPI fetches path from srv, checks the status and decodes the body.
+func getAPI(t *testing.T, srv *httptest.Server, path string, status int, v interface{}) {
+	t.Helper()
+	resp, err := srv.Client().Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Fatalf("GET %s: status %d, want %d", path, resp.StatusCode, status)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+	}
+}
+
+func TestServeFromSnapshots(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	runMain(t, fake, dir, "-all", "-o", "courses.csv")
+	courses, err := loadCoursesFromJSON(filepath.Join(dir, "courses.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newAPIServer(snapshotStore{dir: filepath.Join(dir, "snapshots")}, "202501", courses)
+	srv := httptest.NewServer(api.routes())
+	defer srv.Close()
+
+	var terms []apiTerm
+	getAPI(t, srv, "/terms", http.StatusOK, &terms)
+	if len(terms) != 1 || terms[0].Code != "202501" || !terms[0].Default || terms[0].Description != "Spring 2025" {
+		t.Errorf("terms = %+v", terms)
+	}
+
+	var list []apiCourse
+	getAPI(t, srv, "/courses?subject=csc&level=100", http.StatusOK, &list)
+	if len(list) != 2 || list[0].ID != "CSC110" || list[1].ID != "CSC111" {
+		t.Errorf("courses = %+v", list)
+	}
+	getAPI(t, srv, "/courses?level=x", http.StatusBadRequest, nil)
+
+	var course apiCourseDetail
+	getAPI(t, srv, "/courses/csc/110", http.StatusOK, &course)
+	if course.Title == "" || len(course.Sections) == 0 {
+		t.Fatalf("course = %+v", course)
+	}
+	for _, s := range course.Sections {
+		if s.Subject != "CSC" || s.CourseNumber != "110" || s.CRN == "" {
+			t.Errorf("section of CSC 110: %+v", s)
+		}
+	}
+	getAPI(t, srv, "/courses/CSC/999", http.StatusNotFound, nil)
+
+	var sections []CourseOutput
+	getAPI(t, srv, "/sections/202501/20001", http.StatusOK, &sections)
+	if len(sections) == 0 || sections[0].CRN != "20001" || sections[0].Professor != "Doe, Jane" {
+		t.Errorf("sections = %+v", sections)
+	}
+	getAPI(t, srv, "/sections/202501/99999", http.StatusNotFound, nil)
+	getAPI(t, srv, "/sections/spring/20001", http.StatusBadRequest, nil)
+
+	sections = nil
+	getAPI(t, srv, "/instructors/"+url.PathEscape("Jane Doe"), http.StatusOK, &sections)
+	if len(sections) == 0 || sections[0].CRN != "20001" {
+		t.Errorf("sections of Jane Doe = %+v", sections)
+	}
+	getAPI(t, srv, "/instructors/nobody", http.StatusNotFound, nil)
+}
+
+func TestServeRefreshesOnMiss(t *testing.T) {
+	fake := newFakeUVic(t)
+	savedBanner, savedKuali := bannerBaseURL, kualiBaseURL
+	bannerBaseURL, kualiBaseURL = fake.bannerURL(), fake.kualiURL()
+	defer func() { bannerBaseURL, kualiBaseURL = savedBanner, savedKuali }()
+
+	dir := scratchDir(t)
+	courses, err := loadCoursesFromJSON(filepath.Join(dir, "courses.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newAPIServer(snapshotStore{dir: filepath.Join(dir, "snapshots")}, "202501", courses)
+	api.refresh = true
+	now := time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)
+	api.now = func() time.Time { return now }
+	srv := httptest.NewServer(api.routes())
+	defer srv.Close()
+
+	const search = "/StudentRegistrationSsb/ssb/searchResults/searchResults"
+	var sections []CourseOutput
+	getAPI(t, srv, "/sections/202501/20001", http.StatusOK, &sections)
+	if len(sections) == 0 || sections[0].Title == "" {
+		t.Fatalf("sections = %+v", sections)
+	}
+	searches := fake.count(search)
+	getAPI(t, srv, "/sections/202501/20001", http.StatusOK, &sections)
+	if fake.count(search) != searches {
+		t.Error("a fetched section was fetched again")
+	}
+
+	getAPI(t, srv, "/sections/202501/99999", http.StatusNotFound, nil)
+	searches = fake.count(search)
+	getAPI(t, srv, "/sections/202501/99999", http.StatusNotFound, nil)
+	if fake.count(search) != searches {
+		t.Error("a missing section was searched for again straight away")
+	}
+	now = now.Add(api.missTTL)
+	getAPI(t, srv, "/sections/202501/99999", http.StatusNotFound, nil)
+	if fake.count(search) == searches {
+		t.Error("a missing section wasn't searched for again once the miss expired")
+	}
+
+	searches = fake.count(search)
+	now = now.Add(api.ttl)
+	getAPI(t, srv, "/sections/202501/20001", http.StatusOK, &sections)
+	if fake.count(search) == searches {
+		t.Error("a fetched section wasn't fetched again once it expired")
+	}
+
+	var course apiCourseDetail
+	getAPI(t, srv, "/courses/MATH/100", http.StatusOK, &course)
+	if len(course.Sections) != 2 {
+		t.Errorf("MATH 100 sections = %+v", course.Sections)
+	}
+}
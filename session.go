@@ -1,114 +1,344 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
 	"net/http/cookiejar"
 	"strings"
+	"sync"
 )
 
+// bannerBaseURL is the root of Banner's student registration API; -banner-url
+// overrides it, e.g. to point at a local fake.
+var bannerBaseURL = "https://banner.uvic.ca/StudentRegistrationSsb/ssb"
+
+// Banner answers an expired or unbound session with the term selection page
+// instead of JSON.
+var errSessionExpired = errors.New("banner session expired")
+
+// Session is a single authenticated Banner session. Banner binds a session to
+// one term at a time, so the term handshake is only repeated when a caller
+// asks for a different term or the session expires. Requests hold the
+// binding while they run; a request for another term waits for them.
 type Session struct {
 	client *http.Client
-}
 
-func NewSession() (*Session, error) {
-	client := &http.Client{}
-	return &Session{client: client}, nil
+	mu         sync.Mutex
+	unbound    *sync.Cond // signalled when a term's last request or a handshake is done
+	term       string
+	generation int
+	requests   int  // requests in flight within term
+	rebinding  bool // a handshake is running, without mu held
+	criteria   int  // id of the search whose criteria Banner holds, 0 after a handshake
+
+	// Banner keeps search criteria server-side, so search pages are
+	// fetched one at a time, and a search resets the criteria again if
+	// another search ran since its previous page.
+	searchMu sync.Mutex
+	searches int // ids handed out to searches so far
 }
 
-func (s *Session) fetchCourseInfo(term string, subject string, courseNumber string) (*CourseResponse, error) {
+func NewSession() (*Session, error) {
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, err
 	}
-	client := &http.Client{
-		Jar: jar,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return nil
-		},
-	}
+	client := &http.Client{Jar: jar, Transport: sharedTransport}
+	s := &Session{client: client}
+	s.unbound = sync.NewCond(&s.mu)
+	return s, nil
+}
 
-	initURL := "https://banner.uvic.ca/StudentRegistrationSsb/ssb/term/termSelection?mode=search"
-	if err := makeRequest(client, "GET", initURL, nil); err != nil {
-		return nil, fmt.Errorf("init request failed: %v", err)
-	}
+// bind binds the session to term for one request, performing the
+// termSelection handshake if needed, and returns the handshake generation
+// it observed. The binding can't change until release is called.
+func (s *Session) bind(ctx context.Context, term string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	termURL := "https://banner.uvic.ca/StudentRegistrationSsb/ssb/term/search?mode=search"
-	termData := strings.NewReader(fmt.Sprintf("term=%s&studyPath=&studyPathText=&startDatepicker=&endDatepicker=", term))
-	if err := makeRequest(client, "POST", termURL, termData); err != nil {
-		return nil, fmt.Errorf("term setup failed: %v", err)
+	for s.rebinding || (s.term != term && s.requests > 0) {
+		s.unbound.Wait()
+	}
+	if s.term == term {
+		s.requests++
+		return s.generation, nil
 	}
 
-	searchURL := fmt.Sprintf("https://banner.uvic.ca/StudentRegistrationSsb/ssb/searchResults/searchResults?txt_term=%s&txt_subject=%s&txt_courseNumber=%s&pageOffset=0&pageMaxSize=50&sortColumn=subjectDescription&sortDirection=asc",
-		term, subject, courseNumber)
-
-	req, err := http.NewRequest("GET", searchURL, nil)
+	// The handshake is two round trips; other callers only wait on the
+	// cond meanwhile, rather than on mu
+	s.rebinding = true
+	s.term = ""
+	s.mu.Unlock()
+	err := s.handshake(ctx, term)
+	s.mu.Lock()
+	s.rebinding = false
+	s.unbound.Broadcast()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	req.Header = http.Header{
-		"User-Agent": {"Mozilla/5.0"},
+	s.term = term
+	s.generation++
+	s.criteria = 0
+	s.requests++
+	return s.generation, nil
+}
+
+func (s *Session) handshake(ctx context.Context, term string) error {
+	initURL := bannerBaseURL + "/term/termSelection?mode=search"
+	if err := makeRequest(ctx, s.client, "GET", initURL, nil); err != nil {
+		return fmt.Errorf("init request failed: %v", err)
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	termURL := bannerBaseURL + "/term/search?mode=search"
+	termData := strings.NewReader(fmt.Sprintf("term=%s&studyPath=&studyPathText=&startDatepicker=&endDatepicker=", term))
+	if err := makeRequest(ctx, s.client, "POST", termURL, termData); err != nil {
+		return fmt.Errorf("term setup failed: %v", err)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	body, _ := io.ReadAll(resp.Body)
-	var response CourseResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to decode JSON %v", err)
+// release ends a request started by bind.
+func (s *Session) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests--
+	if s.requests == 0 {
+		s.unbound.Broadcast()
 	}
+}
 
-	return &response, nil
+// expire forgets the bound term so the next request re-runs the handshake.
+// Callers that saw the same expiry concurrently only trigger one handshake.
+func (s *Session) expire(generation int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.generation == generation {
+		s.term = ""
+	}
 }
 
-func (s *Session) fetchSessions(term, crn string) (*DetailedResponse, error) {
-	termURL := "https://banner.uvic.ca/StudentRegistrationSsb/ssb/term/search?mode=search"
-	termData := strings.NewReader(fmt.Sprintf("term=%s&studyPath=&studyPathText=&startDatepicker=&endDatepicker=", term))
+// getJSON fetches url within term and decodes it into v, re-establishing
+// the session once if Banner reports it expired.
+func (s *Session) getJSON(ctx context.Context, term, url string, v interface{}) error {
+	for attempt := 0; ; attempt++ {
+		generation, err := s.bind(ctx, term)
+		if err != nil {
+			return err
+		}
+
+		body, err := s.get(ctx, url)
+		s.release()
+		if errors.Is(err, errSessionExpired) && attempt == 0 {
+			s.expire(generation)
+			continue
+		}
+		if err != nil {
+			return err
+		}
 
-	req, err := http.NewRequest("POST", termURL, termData)
+		if err := json.Unmarshal(body, v); err != nil {
+			return fmt.Errorf("failed to decode JSON %v", err)
+		}
+		return nil
+	}
+}
+
+func (s *Session) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.Header.Set("Accept", "application/json")
+
 	resp, err := s.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	detailURL := fmt.Sprintf("https://banner.uvic.ca/StudentRegistrationSsb/ssb/searchResults/getFacultyMeetingTimes?term=%s&courseReferenceNumber=%s", term, crn)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed with status: %s", resp.Status)
+	}
 
-	req, err = http.NewRequest("GET", detailURL, nil)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	if strings.Contains(resp.Request.URL.Path, "termSelection") ||
+		strings.Contains(resp.Header.Get("Content-Type"), "text/html") ||
+		bytes.HasPrefix(bytes.TrimSpace(body), []byte("<")) {
+		return nil, errSessionExpired
+	}
+
+	return body, nil
+}
+
+func (s *Session) fetchCourseInfo(ctx context.Context, term string, subject string, courseNumber string) (*CourseResponse, error) {
+	var response CourseResponse
+	for section, err := range s.searchSections(ctx, term, subject, courseNumber, &response.TotalCount) {
+		if err != nil {
+			return nil, err
+		}
+		response.Data = append(response.Data, section)
 	}
+	if len(response.Data) < response.TotalCount {
+		return nil, fmt.Errorf("search returned %d of %d sections", len(response.Data), response.TotalCount)
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// Banner caps pageMaxSize server-side, so large courses always need paging.
+const searchPageSize = 50
+
+// searchSections streams every section matching subject and courseNumber,
+// fetching further pages only as the caller consumes them. Either filter may
+// be empty to widen the search. If totalCount is non-nil it receives the
+// count Banner reports.
+func (s *Session) searchSections(ctx context.Context, term, subject, courseNumber string, totalCount *int) iter.Seq2[CourseSection, error] {
+	return func(yield func(CourseSection, error) bool) {
+		id := s.newSearch()
+		for offset := 0; ; {
+			searchURL := fmt.Sprintf("%s/searchResults/searchResults?txt_term=%s&txt_subject=%s&txt_courseNumber=%s&pageOffset=%d&pageMaxSize=%d&sortColumn=subjectDescription&sortDirection=asc",
+				bannerBaseURL, term, subject, courseNumber, offset, searchPageSize)
+
+			page, err := s.searchPage(ctx, id, term, searchURL)
+			if err != nil {
+				yield(CourseSection{}, fmt.Errorf("search page at offset %d: %v", offset, err))
+				return
+			}
+			if totalCount != nil {
+				*totalCount = page.TotalCount
+			}
+			for _, section := range page.Data {
+				if !yield(section, nil) {
+					return
+				}
+			}
+			offset += len(page.Data)
+			if len(page.Data) == 0 || offset >= page.TotalCount {
+				return
+			}
+		}
+	}
+}
+
+func (s *Session) newSearch() int {
+	s.searchMu.Lock()
+	defer s.searchMu.Unlock()
+	s.searches++
+	return s.searches
+}
 
-	resp, err = s.client.Do(req)
+// searchPage fetches one page of search id, first resetting Banner's
+// criteria unless they are still that search's. searchMu is only held for
+// the page, so callers can make other requests, searches included, while
+// they consume it. The reset and the page share one term binding.
+func (s *Session) searchPage(ctx context.Context, id int, term, url string) (*CourseResponse, error) {
+	s.searchMu.Lock()
+	defer s.searchMu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		generation, err := s.bind(ctx, term)
+		if err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		stale := s.criteria != id
+		s.mu.Unlock()
+		var body []byte
+		if stale {
+			err = s.resetSearch(ctx, id)
+		}
+		if err == nil {
+			body, err = s.get(ctx, url)
+		}
+		s.release()
+		if errors.Is(err, errSessionExpired) && attempt == 0 {
+			s.expire(generation)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var page CourseResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to decode JSON %v", err)
+		}
+		return &page, nil
+	}
+}
+
+// findSection looks up a single section by its CRN.
+func (s *Session) findSection(ctx context.Context, term, crn string) (*CourseSection, error) {
+	searchURL := fmt.Sprintf("%s/searchResults/searchResults?txt_term=%s&txt_courseReferenceNumber=%s&pageOffset=0&pageMaxSize=%d",
+		bannerBaseURL, term, crn, searchPageSize)
+	page, err := s.searchPage(ctx, s.newSearch(), term, searchURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	for _, section := range page.Data {
+		if section.CourseReferenceNumber == crn {
+			return &section, nil
+		}
+	}
+	return nil, &sectionNotFoundError{term: term, crn: crn}
+}
 
-	body, _ := io.ReadAll(resp.Body)
+// sectionNotFoundError is returned by findSection when Banner has no such CRN.
+type sectionNotFoundError struct {
+	term, crn string
+}
+
+func (e *sectionNotFoundError) Error() string {
+	return fmt.Sprintf("CRN %s not found in term %s", e.crn, e.term)
+}
+
+// resetSearch clears the criteria of the previous search for search id;
+// without it Banner keeps returning the earlier results regardless of the
+// query string. The caller holds the term binding.
+func (s *Session) resetSearch(ctx context.Context, id int) error {
+	s.mu.Lock()
+	s.criteria = 0
+	s.mu.Unlock()
+	resetURL := bannerBaseURL + "/classSearch/resetDataForm"
+	if err := makeRequest(ctx, s.client, "POST", resetURL, nil); err != nil {
+		return fmt.Errorf("search reset failed: %v", err)
+	}
+	s.mu.Lock()
+	s.criteria = id
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Session) fetchSessions(ctx context.Context, term, crn string) (*DetailedResponse, error) {
+	detailURL := fmt.Sprintf("%s/searchResults/getFacultyMeetingTimes?term=%s&courseReferenceNumber=%s", bannerBaseURL, term, crn)
 
 	var info DetailedResponse
-	if err := json.Unmarshal(body, &info); err != nil {
-		return nil, fmt.Errorf("failed to decode JSON %v", err)
+	if err := s.getJSON(ctx, term, detailURL, &info); err != nil {
+		return nil, err
 	}
 
 	return &info, nil
 }
 
-func makeRequest(client *http.Client, method, url string, body io.Reader) error {
-	req, err := http.NewRequest(method, url, body)
+func makeRequest(ctx context.Context, client *http.Client, method, url string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return err
 	}
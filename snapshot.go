@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snapshotTimeFormat names snapshots by when their crawl finished, in UTC.
+const snapshotTimeFormat = "20060102T150405Z"
+
+// snapshotInfo describes one stored crawl. Hash addresses the rows, so
+// crawls that found exactly the same sections share one object.
+type snapshotInfo struct {
+	ID       string    `json:"id"`
+	Term     string    `json:"term"`
+	Taken    time.Time `json:"taken"`
+	Hash     string    `json:"hash"`
+	Sections int       `json:"sections"`
+	Rows     int       `json:"rows"`
+}
+
+// snapshotStore keeps every complete -all crawl under dir, laid out as
+// <term>/index.json listing the term's snapshots oldest first and
+// <term>/objects/<hash>.json.gz holding their rows.
+type snapshotStore struct {
+	dir string
+}
+
+func (s snapshotStore) indexPath(term string) string {
+	return filepath.Join(s.dir, term, "index.json")
+}
+
+func (s snapshotStore) objectPath(term, hash string) string {
+	return filepath.Join(s.dir, term, "objects", hash+".json.gz")
+}
+
+// writeFileAtomic replaces filename so readers never see a partial file.
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("error replacing %s: %v", filename, err)
+	}
+	return nil
+}
+
+// terms returns the terms with snapshots, newest first.
+func (s snapshotStore) terms() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading snapshots: %v", err)
+	}
+	var terms []string
+	for _, e := range entries {
+		if e.IsDir() && termCodePattern.MatchString(e.Name()) {
+			terms = append(terms, e.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(terms)))
+	return terms, nil
+}
+
+// list returns a term's snapshots, oldest first.
+func (s snapshotStore) list(term string) ([]snapshotInfo, error) {
+	data, err := os.ReadFile(s.indexPath(term))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading snapshot index: %v", err)
+	}
+	var snapshots []snapshotInfo
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %v", s.indexPath(term), err)
+	}
+	return snapshots, nil
+}
+
+func (s snapshotStore) writeIndex(term string, snapshots []snapshotInfo) error {
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.indexPath(term), data)
+}
+
+// sortSnapshotRows puts rows in a fixed order, since crawl workers finish
+// in any order, so that identical crawls hash the same. Rows are ordered by
+// course and meeting first, then by every other field, so no two different
+// rows are ever left in the order the crawl produced them.
+func sortSnapshotRows(rows []CSVExportRow) {
+	type keyed struct {
+		key []string
+		row CSVExportRow
+	}
+	sorted := make([]keyed, len(rows))
+	for i, r := range rows {
+		key := []string{r.Subject, r.CourseNumber, r.Section, r.CRN, r.MeetingType, r.StartDate, r.Days, r.Time, r.Location}
+		key = append(key, csvRecord(r)...)
+		// Faculty is only partly in the columns
+		encoded, _ := json.Marshal(r)
+		sorted[i] = keyed{append(key, string(encoded)), r}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return slices.Compare(sorted[i].key, sorted[j].key) < 0 })
+	for i := range sorted {
+		rows[i] = sorted[i].row
+	}
+}
+
+// save stores rows as a new snapshot of term taken at the given time.
+func (s snapshotStore) save(term string, rows []CSVExportRow, taken time.Time) (snapshotInfo, error) {
+	rows = append([]CSVExportRow(nil), rows...)
+	sortSnapshotRows(rows)
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return snapshotInfo{}, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if err := os.MkdirAll(filepath.Join(s.dir, term, "objects"), 0755); err != nil {
+		return snapshotInfo{}, fmt.Errorf("error creating snapshot directory: %v", err)
+	}
+	object := s.objectPath(term, hash)
+	if _, err := os.Stat(object); os.IsNotExist(err) {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		if _, err := zw.Write(data); err != nil {
+			return snapshotInfo{}, err
+		}
+		if err := zw.Close(); err != nil {
+			return snapshotInfo{}, err
+		}
+		if err := writeFileAtomic(object, b.Bytes()); err != nil {
+			return snapshotInfo{}, err
+		}
+	}
+
+	snapshots, err := s.list(term)
+	if err != nil {
+		return snapshotInfo{}, err
+	}
+	sections := make(map[string]bool)
+	for _, row := range rows {
+		if row.CRN != "" {
+			sections[row.CRN] = true
+		}
+	}
+	info := snapshotInfo{
+		ID:       taken.UTC().Format(snapshotTimeFormat),
+		Term:     term,
+		Taken:    taken.UTC(),
+		Hash:     hash,
+		Sections: len(sections),
+		Rows:     len(rows),
+	}
+	// Two crawls finishing within a second still get their own ids
+	for n := 2; ; n++ {
+		exists := false
+		for _, prev := range snapshots {
+			exists = exists || prev.ID == info.ID
+		}
+		if !exists {
+			break
+		}
+		info.ID = info.Taken.Format(snapshotTimeFormat) + "-" + strconv.Itoa(n)
+	}
+	snapshots = append(snapshots, info)
+	return info, s.writeIndex(term, snapshots)
+}
+
+// find looks up a snapshot of term by id, a prefix of its id or hash, or
+// "latest".
+func (s snapshotStore) find(term, id string) (snapshotInfo, error) {
+	snapshots, err := s.list(term)
+	if err != nil {
+		return snapshotInfo{}, err
+	}
+	if len(snapshots) == 0 {
+		return snapshotInfo{}, fmt.Errorf("no snapshots of %s in %s", term, s.dir)
+	}
+	if id == "latest" {
+		return snapshots[len(snapshots)-1], nil
+	}
+	var found []snapshotInfo
+	for _, info := range snapshots {
+		if info.ID == id {
+			return info, nil
+		}
+		if strings.HasPrefix(info.ID, id) || strings.HasPrefix(info.Hash, id) {
+			found = append(found, info)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return snapshotInfo{}, fmt.Errorf("no snapshot %q of %s (see -snapshots)", id, term)
+	case 1:
+		return found[0], nil
+	}
+	return snapshotInfo{}, fmt.Errorf("snapshot %q of %s is ambiguous: %d match", id, term, len(found))
+}
+
+// load reads a snapshot's rows.
+func (s snapshotStore) load(info snapshotInfo) ([]CSVExportRow, error) {
+	f, err := os.Open(s.objectPath(info.Term, info.Hash))
+	if err != nil {
+		return nil, fmt.Errorf("error opening snapshot %s: %v", info.ID, err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot %s: %v", info.ID, err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot %s: %v", info.ID, err)
+	}
+	var rows []CSVExportRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot %s: %v", info.ID, err)
+	}
+	return rows, nil
+}
+
+// prune removes snapshots taken before cutoff, always keeping each term's
+// newest, and deletes objects no remaining snapshot refers to.
+func (s snapshotStore) prune(cutoff time.Time) ([]snapshotInfo, error) {
+	terms, err := s.terms()
+	if err != nil {
+		return nil, err
+	}
+	var removed []snapshotInfo
+	for _, term := range terms {
+		snapshots, err := s.list(term)
+		if err != nil {
+			return removed, err
+		}
+		var kept []snapshotInfo
+		for i, info := range snapshots {
+			if info.Taken.Before(cutoff) && i < len(snapshots)-1 {
+				removed = append(removed, info)
+			} else {
+				kept = append(kept, info)
+			}
+		}
+		if len(kept) == len(snapshots) {
+			continue
+		}
+		if err := s.writeIndex(term, kept); err != nil {
+			return removed, err
+		}
+
+		used := make(map[string]bool)
+		for _, info := range kept {
+			used[info.Hash] = true
+		}
+		objects, err := os.ReadDir(filepath.Join(s.dir, term, "objects"))
+		if err != nil {
+			return removed, fmt.Errorf("error reading snapshots: %v", err)
+		}
+		for _, e := range objects {
+			if hash, ok := strings.CutSuffix(e.Name(), ".json.gz"); ok && !used[hash] {
+				if err := os.Remove(filepath.Join(s.dir, term, "objects", e.Name())); err != nil {
+					return removed, err
+				}
+			}
+		}
+	}
+	return removed, nil
+}
+
+// parseAge reads a -prune-snapshots age: a Go duration such as 72h, or a
+// number of days such as 30d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 30d or 72h)", s)
+	}
+	return d, nil
+}
+
+// printSnapshots lists the snapshots of each term, newest term first.
+func (s snapshotStore) printSnapshots(w io.Writer, terms []string) error {
+	if len(terms) == 0 {
+		fmt.Fprintf(w, "No snapshots in %s\n", s.dir)
+		return nil
+	}
+	for i, term := range terms {
+		snapshots, err := s.list(term)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s):\n", termName(term), term)
+		for _, info := range snapshots {
+			fmt.Fprintf(w, "  %-20s %s  %5d sections  %s\n", info.ID, info.Taken.Local().Format("2006-01-02 15:04"), info.Sections, info.Hash[:12])
+		}
+	}
+	return nil
+}
+
+// sectionEvent is a snapshot in which a section appeared or disappeared.
+type sectionEvent struct {
+	Snapshot snapshotInfo
+	Present  bool
+	Course   string
+}
+
+// sectionHistory walks a term's snapshots oldest first and returns each
+// time crn appeared in or vanished from a crawl.
+func (s snapshotStore) sectionHistory(term, crn string) ([]sectionEvent, error) {
+	snapshots, err := s.list(term)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshots of %s in %s", term, s.dir)
+	}
+
+	var events []sectionEvent
+	present := false
+	loaded := make(map[string]string)
+	for _, info := range snapshots {
+		course, ok := loaded[info.Hash]
+		if !ok {
+			rows, err := s.load(info)
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				if row.CRN == crn {
+					course = fmt.Sprintf("%s %s %s %s", row.Subject, row.CourseNumber, row.Section, row.CourseName)
+					break
+				}
+			}
+			loaded[info.Hash] = course
+		}
+		if (course != "") != present {
+			present = !present
+			events = append(events, sectionEvent{Snapshot: info, Present: present, Course: course})
+		}
+	}
+	return events, nil
+}
+
+func printSectionHistory(w io.Writer, crn string, events []sectionEvent) {
+	if len(events) == 0 {
+		fmt.Fprintf(w, "CRN %s is not in any snapshot\n", crn)
+		return
+	}
+	for i, e := range events {
+		what := "appeared"
+		switch {
+		case !e.Present:
+			what = "disappeared"
+		case i > 0:
+			what = "reappeared"
+		}
+		line := fmt.Sprintf("%s  %s  %s", e.Snapshot.Taken.Local().Format("2006-01-02 15:04"), e.Snapshot.ID, what)
+		if e.Present {
+			line += ": " + e.Course
+		}
+		fmt.Fprintln(w, line)
+	}
+}
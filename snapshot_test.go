@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotStore(t *testing.T) {
+	store := snapshotStore{dir: t.TempDir()}
+	day := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	rows := []CSVExportRow{
+		{Term: "202509", Subject: "CSC", CourseNumber: "110", CRN: "10001", Section: "A01"},
+		{Term: "202509", Subject: "CSC", CourseNumber: "110", CRN: "10002", Section: "A02"},
+	}
+
+	first, err := store.save("202509", rows, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same sections in a different order are the same content
+	second, err := store.save("202509", []CSVExportRow{rows[1], rows[0]}, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Hash != second.Hash || first.Sections != 2 {
+		t.Errorf("snapshots %+v and %+v should share content", first, second)
+	}
+	third, err := store.save("202509", rows[:1], day.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Hash == first.Hash {
+		t.Error("different rows got the same hash")
+	}
+	fourth, err := store.save("202509", rows, day.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fourth.ID != "20250703T120000Z-2" {
+		t.Errorf("snapshot in the same second got id %s", fourth.ID)
+	}
+
+	if info, err := store.find("202509", first.ID[:6]); err == nil {
+		t.Errorf("ambiguous id prefix found %s", info.ID)
+	}
+	if info, err := store.find("202509", third.Hash[:10]); err != nil || info.ID != third.ID {
+		t.Errorf("find by hash = %+v, %v", info, err)
+	}
+	latest, err := store.find("202509", "latest")
+	if err != nil || latest.ID != fourth.ID {
+		t.Errorf("latest = %+v, %v", latest, err)
+	}
+	loaded, err := store.load(third)
+	if err != nil || len(loaded) != 1 || loaded[0].CRN != "10001" {
+		t.Errorf("load = %+v, %v", loaded, err)
+	}
+
+	events, err := store.sectionHistory("202509", "10002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var history []string
+	for _, e := range events {
+		history = append(history, e.Snapshot.ID)
+	}
+	if want := first.ID + " " + third.ID + " " + fourth.ID; strings.Join(history, " ") != want {
+		t.Errorf("history of 10002 = %v, want %s", history, want)
+	}
+
+	removed, err := store.prune(day.Add(36 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("pruned %d snapshots, want 2", len(removed))
+	}
+	objects, _ := os.ReadDir(filepath.Join(store.dir, "202509", "objects"))
+	if len(objects) != 2 {
+		t.Errorf("%d objects left after pruning, want 2", len(objects))
+	}
+
+	// The newest snapshot of a term survives any age
+	if removed, _ := store.prune(day.Add(100 * time.Hour)); len(removed) != 1 {
+		t.Errorf("pruned %d snapshots, want 1", len(removed))
+	}
+	if snapshots, _ := store.list("202509"); len(snapshots) != 1 || snapshots[0].ID != fourth.ID {
+		t.Errorf("left %+v", snapshots)
+	}
+}
+
+func TestSnapshotOrderIsTotal(t *testing.T) {
+	store := snapshotStore{dir: t.TempDir()}
+	day := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	// Meetings of one section that only differ outside the primary sort key
+	base := CSVExportRow{Term: "202509", Subject: "CSC", CourseNumber: "110", CRN: "10001", Section: "A01"}
+	endDate, waitlist, linked, faculty := base, base, base, base
+	endDate.EndDate = "04/04/2025"
+	waitlist.WaitCount = 3
+	linked.LinkGroup = "L1"
+	faculty.Faculty = []Faculty{{BannerId: "V001", DisplayName: "Doe, Jane"}}
+	rows := []CSVExportRow{base, endDate, waitlist, linked, faculty}
+
+	first, err := store.save("202509", rows, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed := []CSVExportRow{faculty, linked, waitlist, endDate, base}
+	second, err := store.save("202509", reversed, day.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Hash != second.Hash {
+		t.Error("the same rows in another order hashed differently")
+	}
+}
+
+func TestAllKeepsSnapshots(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	runMain(t, fake, dir, "-all")
+	original := readExport(t, filepath.Join(dir, "courses.csv"))
+
+	// CRN 20002 is cancelled before the next crawl
+	fake.mu.Lock()
+	var kept []map[string]interface{}
+	for _, section := range fake.sections["202501"] {
+		if section["courseReferenceNumber"] != "20002" {
+			kept = append(kept, section)
+		}
+	}
+	fake.sections["202501"] = kept
+	fake.mu.Unlock()
+	out := runMain(t, fake, dir, "-all")
+	if !strings.Contains(out, "Saved snapshot") {
+		t.Errorf("second crawl saved no snapshot:\n%s", out)
+	}
+
+	out = runMain(t, fake, dir, "-snapshots")
+	if !strings.Contains(out, "Spring 2025 (202501):") || strings.Count(out, "sections") != 2 {
+		t.Errorf("snapshot list:\n%s", out)
+	}
+	out = runMain(t, fake, dir, "-section-history", "20002")
+	if !strings.Contains(out, "appeared: CSC 110") || !strings.Contains(out, "disappeared") {
+		t.Errorf("section history:\n%s", out)
+	}
+
+	store := snapshotStore{dir: filepath.Join(dir, "snapshots")}
+	snapshots, err := store.list("202501")
+	if err != nil || len(snapshots) != 2 {
+		t.Fatalf("snapshots = %+v, %v", snapshots, err)
+	}
+	runMain(t, fake, dir, "-all", "-snapshot", snapshots[0].ID, "-o", "first.csv")
+	restored := readExport(t, filepath.Join(dir, "first.csv"))
+	if strings.Join(exportedCRNs(restored), ",") != strings.Join(exportedCRNs(original), ",") {
+		t.Errorf("snapshot export %v, want %v", exportedCRNs(restored), exportedCRNs(original))
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE terms (
+	code        TEXT PRIMARY KEY,
+	description TEXT NOT NULL
+);
+CREATE TABLE courses (
+	id                  INTEGER PRIMARY KEY,
+	subject             TEXT NOT NULL,
+	number              TEXT NOT NULL,
+	title               TEXT NOT NULL,
+	subject_description TEXT NOT NULL DEFAULT '',
+	catalog_id          TEXT NOT NULL DEFAULT '',
+	UNIQUE (subject, number)
+);
+CREATE TABLE sections (
+	term                 TEXT NOT NULL REFERENCES terms (code),
+	crn                  TEXT NOT NULL,
+	course_id            INTEGER NOT NULL REFERENCES courses (id),
+	section              TEXT NOT NULL,
+	instructional_method TEXT NOT NULL,
+	campus               TEXT NOT NULL,
+	credit_hours         TEXT NOT NULL,
+	enrollment           INTEGER NOT NULL,
+	maximum_enrollment   INTEGER NOT NULL,
+	seats_available      INTEGER NOT NULL,
+	wait_count           INTEGER NOT NULL,
+	wait_capacity        INTEGER NOT NULL,
+	link_group           TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (term, crn)
+);
+CREATE TABLE buildings (
+	code TEXT PRIMARY KEY,
+	name TEXT NOT NULL
+);
+CREATE TABLE rooms (
+	id            INTEGER PRIMARY KEY,
+	building_code TEXT NOT NULL REFERENCES buildings (code),
+	number        TEXT NOT NULL,
+	UNIQUE (building_code, number)
+);
+CREATE TABLE meetings (
+	id            INTEGER PRIMARY KEY,
+	term          TEXT NOT NULL,
+	crn           TEXT NOT NULL,
+	schedule_type TEXT NOT NULL,
+	meeting_type  TEXT NOT NULL,
+	description   TEXT NOT NULL,
+	campus        TEXT NOT NULL,
+	room_id       INTEGER REFERENCES rooms (id),
+	begin_time    TEXT,
+	end_time      TEXT,
+	start_date    TEXT,
+	end_date      TEXT,
+	monday        INTEGER NOT NULL,
+	tuesday       INTEGER NOT NULL,
+	wednesday     INTEGER NOT NULL,
+	thursday      INTEGER NOT NULL,
+	friday        INTEGER NOT NULL,
+	saturday      INTEGER NOT NULL,
+	sunday        INTEGER NOT NULL,
+	FOREIGN KEY (term, crn) REFERENCES sections (term, crn)
+);
+CREATE TABLE instructors (
+	id        INTEGER PRIMARY KEY,
+	banner_id TEXT NOT NULL,
+	name      TEXT NOT NULL,
+	email     TEXT NOT NULL
+);
+CREATE TABLE section_instructors (
+	term          TEXT NOT NULL,
+	crn           TEXT NOT NULL,
+	instructor_id INTEGER NOT NULL REFERENCES instructors (id),
+	is_primary    INTEGER NOT NULL,
+	PRIMARY KEY (term, crn, instructor_id),
+	FOREIGN KEY (term, crn) REFERENCES sections (term, crn)
+);
+CREATE INDEX meetings_section ON meetings (term, crn);
+CREATE INDEX meetings_room ON meetings (room_id);
+CREATE INDEX sections_course ON sections (course_id);
+`
+
+// termName turns a term code such as 202509 into "Fall 2025".
+func termName(code string) string {
+	if len(code) != 6 {
+		return code
+	}
+	season := map[string]string{"01": "Spring", "05": "Summer", "09": "Fall"}[code[4:]]
+	if season == "" {
+		return code
+	}
+	return season + " " + code[:4]
+}
+
+// sqlDate converts Banner's MM/DD/YYYY dates to ISO 8601 so they compare
+// correctly in SQL.
+func sqlDate(date string) interface{} {
+	t, ok := parseBannerDate(date)
+	if !ok {
+		return nil
+	}
+	return t.Format("2006-01-02")
+}
+
+// sqliteExport normalizes export rows into the tables of sqliteSchema,
+// remembering the ids it has already assigned.
+type sqliteExport struct {
+	tx          *sql.Tx
+	terms       map[string]bool
+	courses     map[string]int64
+	sections    map[string]bool
+	buildings   map[string]bool
+	rooms       map[string]int64
+	instructors map[string]int64
+	teaching    map[string]bool
+}
+
+func (e *sqliteExport) exec(query string, args ...interface{}) (int64, error) {
+	result, err := e.tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (e *sqliteExport) term(code string) error {
+	if e.terms[code] {
+		return nil
+	}
+	e.terms[code] = true
+	_, err := e.exec(`INSERT INTO terms (code, description) VALUES (?, ?)`, code, termName(code))
+	return err
+}
+
+func (e *sqliteExport) course(subject, number, title, subjectDescription, catalogID string) (int64, error) {
+	key := subject + " " + number
+	if id, ok := e.courses[key]; ok {
+		return id, nil
+	}
+	id, err := e.exec(`INSERT INTO courses (subject, number, title, subject_description, catalog_id) VALUES (?, ?, ?, ?, ?)`,
+		subject, number, title, subjectDescription, catalogID)
+	if err != nil {
+		return 0, err
+	}
+	e.courses[key] = id
+	return id, nil
+}
+
+func (e *sqliteExport) room(row CSVExportRow) (interface{}, error) {
+	if row.BuildingCode == "" || row.RoomNumber == "" {
+		return nil, nil
+	}
+	if !e.buildings[row.BuildingCode] {
+		e.buildings[row.BuildingCode] = true
+		if _, err := e.exec(`INSERT INTO buildings (code, name) VALUES (?, ?)`, row.BuildingCode, row.BuildingName); err != nil {
+			return nil, err
+		}
+	}
+	key := row.BuildingCode + " " + row.RoomNumber
+	if id, ok := e.rooms[key]; ok {
+		return id, nil
+	}
+	id, err := e.exec(`INSERT INTO rooms (building_code, number) VALUES (?, ?)`, row.BuildingCode, row.RoomNumber)
+	if err != nil {
+		return nil, err
+	}
+	e.rooms[key] = id
+	return id, nil
+}
+
+func (e *sqliteExport) instructor(f Faculty) (int64, error) {
+	key := f.BannerId
+	if key == "" {
+		key = f.DisplayName + " " + f.EmailAddress
+	}
+	if id, ok := e.instructors[key]; ok {
+		return id, nil
+	}
+	id, err := e.exec(`INSERT INTO instructors (banner_id, name, email) VALUES (?, ?, ?)`, f.BannerId, f.DisplayName, f.EmailAddress)
+	if err != nil {
+		return 0, err
+	}
+	e.instructors[key] = id
+	return id, nil
+}
+
+func (e *sqliteExport) section(row CSVExportRow, courseID int64) error {
+	key := row.Term + " " + row.CRN
+	if !e.sections[key] {
+		e.sections[key] = true
+		_, err := e.exec(`INSERT INTO sections (term, crn, course_id, section, instructional_method, campus, credit_hours,
+			enrollment, maximum_enrollment, seats_available, wait_count, wait_capacity, link_group) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			row.Term, row.CRN, courseID, row.Section, row.InstructionalMethod, row.CampusDescription, row.Units,
+			row.Enrollment, row.MaximumEnrollment, row.SeatsAvailable, row.WaitCount, row.WaitCapacity, row.LinkGroup)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range row.Faculty {
+		id, err := e.instructor(f)
+		if err != nil {
+			return err
+		}
+		teachingKey := fmt.Sprintf("%s %d", key, id)
+		if e.teaching[teachingKey] {
+			continue
+		}
+		e.teaching[teachingKey] = true
+		if _, err := e.exec(`INSERT INTO section_instructors (term, crn, instructor_id, is_primary) VALUES (?, ?, ?, ?)`,
+			row.Term, row.CRN, id, f.PrimaryIndicator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// meeting stores the meeting a row describes. Sections Banner lists without
+// any meeting times only get their section row.
+func (e *sqliteExport) meeting(row CSVExportRow) error {
+	if row.MeetingType == "" && row.Time == "" && row.Days == "" {
+		return nil
+	}
+	roomID, err := e.room(row)
+	if err != nil {
+		return err
+	}
+	var begin, end interface{}
+	if from, to, ok := strings.Cut(row.Time, "-"); ok {
+		begin, end = from, to
+	}
+	_, err = e.exec(`INSERT INTO meetings (term, crn, schedule_type, meeting_type, description, campus, room_id,
+		begin_time, end_time, start_date, end_date, monday, tuesday, wednesday, thursday, friday, saturday, sunday)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		row.Term, row.CRN, row.ScheduleType, row.MeetingType, row.MeetingDescription, row.Campus, roomID,
+		begin, end, sqlDate(row.StartDate), sqlDate(row.EndDate),
+		strings.Contains(row.Days, "M"), strings.Contains(row.Days, "T"), strings.Contains(row.Days, "W"),
+		strings.Contains(row.Days, "R"), strings.Contains(row.Days, "F"), strings.Contains(row.Days, "S"),
+		strings.Contains(row.Days, "U"))
+	return err
+}
+
+// exportSQLite writes rows to a fresh SQLite database at filename, with one
+// table each for terms, courses, sections, meetings, instructors, buildings
+// and rooms. Catalog courses are included even when they have no sections
+// and fill in the subject descriptions and catalog ids.
+func exportSQLite(filename string, rows []CSVExportRow, catalog []Course) error {
+	if isStdout(filename) {
+		return fmt.Errorf("sqlite output must be written to a file, use -o")
+	}
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error replacing %s: %v", filename, err)
+	}
+
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return fmt.Errorf("error creating database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("error creating tables: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	e := &sqliteExport{
+		tx:          tx,
+		terms:       make(map[string]bool),
+		courses:     make(map[string]int64),
+		sections:    make(map[string]bool),
+		buildings:   make(map[string]bool),
+		rooms:       make(map[string]int64),
+		instructors: make(map[string]int64),
+		teaching:    make(map[string]bool),
+	}
+
+	for _, c := range catalog {
+		if _, err := e.course(c.SubjectCode.Name, catalogNumber(c), c.Title, c.SubjectCode.Description, c.CourseID); err != nil {
+			return fmt.Errorf("error writing course %s: %v", c.CourseID, err)
+		}
+	}
+
+	for _, row := range rows {
+		if err := e.term(row.Term); err != nil {
+			return fmt.Errorf("error writing term %s: %v", row.Term, err)
+		}
+		courseID, err := e.course(row.Subject, row.CourseNumber, row.CourseName, "", "")
+		if err != nil {
+			return fmt.Errorf("error writing course %s %s: %v", row.Subject, row.CourseNumber, err)
+		}
+		if row.CRN == "" {
+			continue
+		}
+		if err := e.section(row, courseID); err != nil {
+			return fmt.Errorf("error writing section %s: %v", row.CRN, err)
+		}
+		if err := e.meeting(row); err != nil {
+			return fmt.Errorf("error writing meeting of %s: %v", row.CRN, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing database: %v", err)
+	}
+	return db.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestAllExportsSQLite(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	runMain(t, fake, dir, "-all", "-format=sqlite")
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "courses.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	counts := map[string]int{
+		"terms":               1,
+		"courses":             3,
+		"sections":            7,
+		"buildings":           2,
+		"rooms":               5,
+		"instructors":         3,
+		"section_instructors": 3,
+	}
+	for table, want := range counts {
+		var got int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&got); err != nil {
+			t.Fatalf("counting %s: %v", table, err)
+		}
+		if got != want {
+			t.Errorf("%s has %d rows, want %d", table, got, want)
+		}
+	}
+
+	var room, instructor string
+	err = db.QueryRow(`
+		SELECT r.number, i.name
+		FROM meetings m
+		JOIN rooms r ON r.id = m.room_id
+		JOIN section_instructors si ON si.term = m.term AND si.crn = m.crn
+		JOIN instructors i ON i.id = si.instructor_id
+		WHERE r.building_code = 'ECS' AND m.friday AND m.begin_time >= '13:00'`).Scan(&room, &instructor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room != "125" || instructor != "Smith, Alex" {
+		t.Errorf("got room %s taught by %s, want 125 and Smith, Alex", room, instructor)
+	}
+
+	var linkedToA01 string
+	if err := db.QueryRow(`SELECT group_concat(crn) FROM (SELECT crn FROM sections
+		WHERE link_group = (SELECT link_group FROM sections WHERE crn = '20001') ORDER BY crn)`).Scan(&linkedToA01); err != nil {
+		t.Fatal(err)
+	}
+	if linkedToA01 != "20001,20011,20012" {
+		t.Errorf("sections linked to A01 = %s, want 20001,20011,20012", linkedToA01)
+	}
+
+	var description string
+	if err := db.QueryRow(`SELECT description FROM terms`).Scan(&description); err != nil {
+		t.Fatal(err)
+	}
+	if description != "Spring 2025" {
+		t.Errorf("term description = %q", description)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Term is one entry of Banner's term list.
+type Term struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// termsCacheTTL is how long a fetched term list is trusted. Terms only
+// change a few times a year, around each rollover.
+const termsCacheTTL = 24 * time.Hour
+
+// termsCachePath is where the term list is cached; -terms-cache overrides it.
+var termsCachePath = defaultTermsCachePath()
+
+func defaultTermsCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "terms.json"
+	}
+	return filepath.Join(dir, "vikes-scraper", "terms.json")
+}
+
+type termsCache struct {
+	BaseURL string    `json:"base_url"`
+	Fetched time.Time `json:"fetched"`
+	Terms   []Term    `json:"terms"`
+}
+
+const termsPageSize = 100
+
+// fetchTerms downloads every term Banner offers for class search, newest
+// first. The endpoint pages by page number rather than by offset.
+func fetchTerms(ctx context.Context) ([]Term, error) {
+	client := &http.Client{Transport: sharedTransport}
+
+	var terms []Term
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/classSearch/getTerms?searchTerm=&offset=%d&max=%d", bannerBaseURL, page, termsPageSize)
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("User-Agent", "Mozilla/5.0")
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch terms: %v", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read terms: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("term list request failed with status: %s", resp.Status)
+		}
+
+		var batch []Term
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, fmt.Errorf("failed to decode terms: %v", err)
+		}
+		terms = append(terms, batch...)
+		if len(batch) < termsPageSize {
+			break
+		}
+	}
+
+	sort.Slice(terms, func(i, j int) bool { return terms[i].Code > terms[j].Code })
+	return terms, nil
+}
+
+func readTermsCache() (*termsCache, error) {
+	data, err := os.ReadFile(termsCachePath)
+	if err != nil {
+		return nil, err
+	}
+	var cache termsCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("error decoding term cache: %v", err)
+	}
+	if cache.BaseURL != bannerBaseURL {
+		return nil, fmt.Errorf("term cache is for %s", cache.BaseURL)
+	}
+	return &cache, nil
+}
+
+func writeTermsCache(terms []Term) error {
+	data, err := json.MarshalIndent(termsCache{BaseURL: bannerBaseURL, Fetched: time.Now(), Terms: terms}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(termsCachePath), 0755); err != nil {
+		return fmt.Errorf("error creating term cache directory: %v", err)
+	}
+	return os.WriteFile(termsCachePath, data, 0644)
+}
+
+// loadTerms returns Banner's term list, from the cache while it is fresh.
+// If Banner can't be reached a stale cache is better than nothing.
+func loadTerms(ctx context.Context, refresh bool) ([]Term, error) {
+	cache, cacheErr := readTermsCache()
+	if !refresh && cacheErr == nil && time.Since(cache.Fetched) < termsCacheTTL {
+		return cache.Terms, nil
+	}
+
+	terms, err := fetchTerms(ctx)
+	if err != nil {
+		if cacheErr == nil {
+			fmt.Fprintf(progress, "Warning: using cached term list from %s: %v\n", cache.Fetched.Format(time.DateOnly), err)
+			return cache.Terms, nil
+		}
+		return nil, err
+	}
+	if err := writeTermsCache(terms); err != nil {
+		fmt.Fprintf(progress, "Warning: could not cache term list: %v\n", err)
+	}
+	return terms, nil
+}
+
+var termSeasons = map[string]string{"spring": "01", "summer": "05", "fall": "09"}
+
+// seasonCode returns the code of the term in session at t: Spring runs
+// January to April, Summer May to August and Fall September to December.
+func seasonCode(t time.Time) string {
+	season := "01"
+	switch {
+	case t.Month() >= time.September:
+		season = "09"
+	case t.Month() >= time.May:
+		season = "05"
+	}
+	return fmt.Sprintf("%d%s", t.Year(), season)
+}
+
+var (
+	termCodePattern  = regexp.MustCompile(`^\d{6}$`)
+	termAliasPattern = regexp.MustCompile(`^(spring|summer|fall)[\s_-]*(\d{4})$`)
+)
+
+// resolveTerm turns a term code or alias into a code Banner offers.
+// Besides codes it accepts "current", "next", "latest", a season and year
+// such as "fall2025", and a term's description as Banner lists it.
+func resolveTerm(input string, terms []Term, now time.Time) (string, error) {
+	offered := func(code string) bool {
+		for _, term := range terms {
+			if term.Code == code {
+				return true
+			}
+		}
+		return false
+	}
+	if len(terms) == 0 {
+		return "", fmt.Errorf("Banner lists no terms")
+	}
+
+	alias := strings.ToLower(strings.TrimSpace(input))
+	switch {
+	case termCodePattern.MatchString(alias):
+		if !offered(alias) {
+			return "", fmt.Errorf("term %s is not offered by Banner (see -terms)", input)
+		}
+		return alias, nil
+
+	case alias == "latest":
+		return terms[0].Code, nil
+
+	case alias == "current":
+		// Between terms, or before Banner lists the term in session, fall
+		// back to the most recent one that has started
+		current := seasonCode(now)
+		for _, term := range terms {
+			if term.Code <= current {
+				return term.Code, nil
+			}
+		}
+		return "", fmt.Errorf("no term in session on %s (see -terms)", now.Format(time.DateOnly))
+
+	case alias == "next":
+		current := seasonCode(now)
+		next := ""
+		for _, term := range terms {
+			if term.Code > current {
+				next = term.Code
+			}
+		}
+		if next == "" {
+			return "", fmt.Errorf("Banner does not list a term after %s yet", termName(current))
+		}
+		return next, nil
+	}
+
+	if m := termAliasPattern.FindStringSubmatch(alias); m != nil {
+		code := m[2] + termSeasons[m[1]]
+		if !offered(code) {
+			return "", fmt.Errorf("%s (%s) is not offered by Banner (see -terms)", termName(code), code)
+		}
+		return code, nil
+	}
+	for _, term := range terms {
+		if strings.EqualFold(term.Description, strings.TrimSpace(input)) {
+			return term.Code, nil
+		}
+	}
+	return "", fmt.Errorf("unknown term %q (use a code like 202509, current, next, latest or fall2025)", input)
+}
+
+// checkTerm resolves -semester before anything is crawled. A plain term
+// code is still accepted when the term list can't be fetched at all.
+func checkTerm(ctx context.Context, input string) (string, error) {
+	terms, err := loadTerms(ctx, false)
+	if err != nil {
+		if termCodePattern.MatchString(input) {
+			fmt.Fprintf(progress, "Warning: could not check term %s: %v\n", input, err)
+			return input, nil
+		}
+		return "", fmt.Errorf("error resolving term %q: %v", input, err)
+	}
+	return resolveTerm(input, terms, time.Now())
+}
+
+// localTerm resolves -semester for commands that only read what earlier
+// runs stored, without contacting Banner. Codes and season aliases stand
+// as given; other aliases are resolved against the cached term list, or
+// failing that against the stored terms, so "current" becomes the newest
+// stored term that has started.
+func localTerm(input string, stored []string) (string, error) {
+	alias := strings.ToLower(strings.TrimSpace(input))
+	if termCodePattern.MatchString(alias) {
+		return alias, nil
+	}
+	if m := termAliasPattern.FindStringSubmatch(alias); m != nil {
+		return m[2] + termSeasons[m[1]], nil
+	}
+
+	var terms []Term
+	if cache, err := readTermsCache(); err == nil {
+		terms = cache.Terms
+	} else {
+		codes := append([]string(nil), stored...)
+		sort.Sort(sort.Reverse(sort.StringSlice(codes)))
+		for _, code := range codes {
+			terms = append(terms, Term{Code: code, Description: termName(code)})
+		}
+	}
+	if len(terms) == 0 {
+		return "", fmt.Errorf("can't resolve term %q without Banner: nothing is stored yet and no term list is cached (use a term code)", input)
+	}
+	return resolveTerm(input, terms, time.Now())
+}
+
+// printTerms lists the terms Banner offers, marking the current one.
+func printTerms(w io.Writer, terms []Term, now time.Time) {
+	current, _ := resolveTerm("current", terms, now)
+	for _, term := range terms {
+		marker := " "
+		if term.Code == current {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %s  %s\n", marker, term.Code, term.Description)
+	}
+}
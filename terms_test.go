@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveTerm(t *testing.T) {
+	terms := []Term{
+		{"202609", "Fall 2026"},
+		{"202605", "Summer 2026"},
+		{"202601", "Spring 2026"},
+		{"202509", "Fall 2025 (View Only)"},
+	}
+	october := time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input string
+		now   time.Time
+		want  string
+	}{
+		{"202601", june, "202601"},
+		{"current", june, "202605"},
+		{"next", june, "202609"},
+		{"latest", june, "202609"},
+		{"fall2025", june, "202509"},
+		{"Spring 2026", june, "202601"},
+		{"fall-2026", june, "202609"},
+		{"fall 2025 (view only)", june, "202509"},
+		// Banner lists Fall 2026 as in session
+		{"current", october, "202609"},
+		{"Current", october, "202609"},
+	}
+	for _, tt := range tests {
+		got, err := resolveTerm(tt.input, terms, tt.now)
+		if err != nil {
+			t.Errorf("resolveTerm(%q): %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveTerm(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"202409", "current2", "winter2026", "fall2024"} {
+		if got, err := resolveTerm(input, terms, june); err == nil {
+			t.Errorf("resolveTerm(%q) = %s, want an error", input, got)
+		}
+	}
+	if got, err := resolveTerm("next", terms, october); err == nil {
+		t.Errorf("resolveTerm(next) = %s after the last listed term, want an error", got)
+	}
+}
+
+func TestTermValidation(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+
+	out := runMain(t, fake, dir, "-semester=spring2025", "-course", "CSC", "110")
+	if !strings.Contains(out, "Using term 202501 (Spring 2025)") || !strings.Contains(out, "Section A01 (CRN: 20001)") {
+		t.Errorf("alias not resolved:\n%s", out)
+	}
+
+	searches := fake.count("/StudentRegistrationSsb/ssb/searchResults/searchResults")
+	out = runMain(t, fake, dir, "-semester=202409", "-course", "CSC", "110")
+	if !strings.Contains(out, "term 202409 is not offered by Banner") {
+		t.Errorf("unknown term not rejected:\n%s", out)
+	}
+	if n := fake.count("/StudentRegistrationSsb/ssb/searchResults/searchResults"); n != searches {
+		t.Errorf("searched Banner for a term it does not offer")
+	}
+	// The second run was answered from the cache
+	if n := fake.count("/StudentRegistrationSsb/ssb/classSearch/getTerms"); n != 1 {
+		t.Errorf("fetched the term list %d times, want 1", n)
+	}
+
+	out = runMain(t, fake, dir, "-terms")
+	if !strings.Contains(out, "202501  Spring 2025 (View Only)") || !strings.Contains(out, "202609  Fall 2026") {
+		t.Errorf("unexpected term listing:\n%s", out)
+	}
+}
+
+func TestLocalTerm(t *testing.T) {
+	saved := termsCachePath
+	termsCachePath = filepath.Join(t.TempDir(), "terms.json")
+	defer func() { termsCachePath = saved }()
+
+	stored := []string{"202401", "202409", "202405"}
+	tests := []struct{ input, want string }{
+		{"202209", "202209"},
+		{"fall2030", "203009"},
+		{"current", "202409"},
+		{"latest", "202409"},
+	}
+	for _, tt := range tests {
+		got, err := localTerm(tt.input, stored)
+		if err != nil || got != tt.want {
+			t.Errorf("localTerm(%q) = %s, %v, want %s", tt.input, got, err, tt.want)
+		}
+	}
+	if got, err := localTerm("current", nil); err == nil {
+		t.Errorf("localTerm(current) with nothing stored = %s, want an error", got)
+	}
+}
+
+func TestStoredDataReadOffline(t *testing.T) {
+	fake := newFakeUVic(t)
+	dir := scratchDir(t)
+	runMain(t, fake, dir, "-all", "-o", "courses.csv")
+	runMain(t, fake, dir, "-collect", "-polls=1", "CSC")
+
+	// No Banner and no cached term list: the default term comes from what
+	// is stored
+	fake.Close()
+	if err := os.Remove(filepath.Join(dir, "terms.json")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-section-history", "20001"}, "appeared: CSC 110 A01"},
+		{[]string{"-enrollment-report", "CSC"}, "CSC 110 A01 (CRN 20001)"},
+		{[]string{"-all", "-snapshot", "latest", "-o", "snapshot.csv"}, "Exported"},
+		{[]string{"-diff", "latest", "latest"}, "0 added, 0 cancelled, 0 changed"},
+	} {
+		out := runMain(t, fake, dir, append([]string{"-semester=current"}, tt.args...)...)
+		if !strings.Contains(out, tt.want) {
+			t.Errorf("%v offline:\n%s", tt.args, out)
+		}
+	}
+}
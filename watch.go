@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Seat transitions -watch reports.
+const (
+	watchOpened         = "opened"
+	watchFilled         = "filled"
+	watchWaitlistOpened = "waitlist_opened"
+	watchWaitlistFilled = "waitlist_filled"
+)
+
+// WatchEvent is one seat transition of a watched section.
+type WatchEvent struct {
+	Time           time.Time `json:"time"`
+	Event          string    `json:"event"`
+	Term           string    `json:"term"`
+	CRN            string    `json:"crn"`
+	Subject        string    `json:"subject"`
+	CourseNumber   string    `json:"course_number"`
+	Section        string    `json:"section"`
+	Title          string    `json:"title"`
+	SeatsAvailable int       `json:"seats_available"`
+	WaitAvailable  int       `json:"wait_available"`
+}
+
+func (e WatchEvent) course() string {
+	return fmt.Sprintf("%s %s %s (CRN %s)", e.Subject, e.CourseNumber, e.Section, e.CRN)
+}
+
+// summary describes the event on one line, without its time.
+func (e WatchEvent) summary() string {
+	switch e.Event {
+	case watchOpened:
+		return fmt.Sprintf("%s opened: %d seats available", e.course(), e.SeatsAvailable)
+	case watchFilled:
+		return fmt.Sprintf("%s is full", e.course())
+	case watchWaitlistOpened:
+		return fmt.Sprintf("%s waitlist opened: %d spots available", e.course(), e.WaitAvailable)
+	case watchWaitlistFilled:
+		return fmt.Sprintf("%s waitlist is full", e.course())
+	}
+	return fmt.Sprintf("%s %s", e.course(), e.Event)
+}
+
+// hasSeats follows the schedule generator: a section is full once it is
+// closed and has no seats left.
+func hasSeats(s CourseSection) bool {
+	return s.OpenSection || s.SeatsAvailable > 0
+}
+
+// seatTransitions lists the events between two polls of a section.
+func seatTransitions(prev, cur CourseSection) []string {
+	var events []string
+	switch {
+	case !hasSeats(prev) && hasSeats(cur):
+		events = append(events, watchOpened)
+	case hasSeats(prev) && !hasSeats(cur):
+		events = append(events, watchFilled)
+	}
+	switch {
+	case prev.WaitAvailable <= 0 && cur.WaitAvailable > 0:
+		events = append(events, watchWaitlistOpened)
+	case prev.WaitAvailable > 0 && cur.WaitAvailable <= 0:
+		events = append(events, watchWaitlistFilled)
+	}
+	return events
+}
+
+// Notifier delivers watch events somewhere.
+type Notifier interface {
+	Notify(ctx context.Context, e WatchEvent) error
+}
+
+// streamNotifier writes events as text lines, or JSON lines for -format=json.
+type streamNotifier struct {
+	w        io.Writer
+	jsonMode bool
+}
+
+func (n streamNotifier) Notify(ctx context.Context, e WatchEvent) error {
+	if n.jsonMode {
+		return json.NewEncoder(n.w).Encode(e)
+	}
+	_, err := fmt.Fprintf(n.w, "%s %s\n", e.Time.Local().Format(time.DateTime), e.summary())
+	return err
+}
+
+// webhookNotifier POSTs each event as JSON.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n webhookNotifier) Notify(ctx context.Context, e WatchEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook failed with status: %s", resp.Status)
+	}
+	return nil
+}
+
+// smtpNotifier emails each event. Auth is nil for relays that don't need it.
+type smtpNotifier struct {
+	addr    string
+	from    string
+	to      []string
+	auth    smtp.Auth
+	timeout time.Duration // bound on each email, so a silent server can't stall -watch
+}
+
+func (n smtpNotifier) Notify(ctx context.Context, e WatchEvent) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", e.summary())
+	fmt.Fprintf(&msg, "Date: %s\r\n", e.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s %s: %s\r\n\r\n", e.Subject, e.CourseNumber, e.Title)
+	fmt.Fprintf(&msg, "%s\r\n", e.summary())
+	fmt.Fprintf(&msg, "Term: %s\r\nSeats available: %d\r\nWaitlist spots available: %d\r\n", termName(e.Term), e.SeatsAvailable, e.WaitAvailable)
+
+	if err := n.send(ctx, []byte(msg.String())); err != nil {
+		return fmt.Errorf("email failed: %v", err)
+	}
+	return nil
+}
+
+// send is smtp.SendMail bounded by ctx and n.timeout: the connection's
+// deadline covers the whole exchange and cancelling ctx closes it.
+func (n smtpNotifier) send(ctx context.Context, msg []byte) error {
+	if n.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.timeout)
+		defer cancel()
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(n.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(n.auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// newSMTPNotifier emails events through addr. Credentials, if the server
+// needs them, come from SMTP_USERNAME and SMTP_PASSWORD rather than flags
+// so they stay out of shell history.
+func newSMTPNotifier(addr, from, to string) (smtpNotifier, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return smtpNotifier{}, fmt.Errorf("invalid -smtp address %q: %v", addr, err)
+	}
+	var recipients []string
+	for _, r := range strings.Split(to, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			recipients = append(recipients, r)
+		}
+	}
+	if from == "" || len(recipients) == 0 {
+		return smtpNotifier{}, fmt.Errorf("-smtp needs -smtp-from and -smtp-to")
+	}
+	n := smtpNotifier{addr: addr, from: from, to: recipients, timeout: 30 * time.Second}
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		n.auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return n, nil
+}
+
+// minWatchInterval keeps -watch from hammering Banner during add/drop,
+// when everyone else is refreshing too.
+const minWatchInterval = 10 * time.Second
+
+// watcher polls sections and notifies on seat transitions. The first poll
+// of a section only records its state.
+type watcher struct {
+	term      string
+	fetch     func(ctx context.Context, term, crn string) (*CourseSection, error)
+	notifiers []Notifier
+	last      map[string]CourseSection
+}
+
+// poll checks each CRN once. A CRN that can't be fetched is reported and
+// retried on the next poll.
+func (w *watcher) poll(ctx context.Context, crns []string) {
+	for _, crn := range crns {
+		if ctx.Err() != nil {
+			return
+		}
+		section, err := w.fetch(ctx, w.term, crn)
+		if err != nil {
+			fmt.Fprintf(progress, "Error checking CRN %s: %v\n", crn, err)
+			continue
+		}
+
+		prev, seen := w.last[crn]
+		w.last[crn] = *section
+		if !seen {
+			fmt.Fprintf(progress, "Watching %s %s %s (CRN %s): %d seats, %d waitlist spots available\n",
+				section.Subject, section.CourseNumber, section.Section, crn, section.SeatsAvailable, section.WaitAvailable)
+			continue
+		}
+		for _, event := range seatTransitions(prev, *section) {
+			w.notify(ctx, WatchEvent{
+				Time:           time.Now(),
+				Event:          event,
+				Term:           w.term,
+				CRN:            crn,
+				Subject:        section.Subject,
+				CourseNumber:   section.CourseNumber,
+				Section:        section.Section,
+				Title:          section.CourseTitle,
+				SeatsAvailable: section.SeatsAvailable,
+				WaitAvailable:  section.WaitAvailable,
+			})
+		}
+	}
+}
+
+// notify hands an event to every notifier; one failing doesn't stop the
+// others or the watch.
+func (w *watcher) notify(ctx context.Context, e WatchEvent) {
+	for _, n := range w.notifiers {
+		if err := n.Notify(ctx, e); err != nil {
+			fmt.Fprintf(progress, "Warning: %v\n", err)
+		}
+	}
+}
+
+// run polls every interval until ctx is done, or polls times if that is
+// positive.
+func (w *watcher) run(ctx context.Context, crns []string, interval time.Duration, polls int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for n := 1; ; n++ {
+		w.poll(ctx, crns)
+		if polls > 0 && n >= polls {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
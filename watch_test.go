@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSeatTransitions(t *testing.T) {
+	full := CourseSection{SeatsAvailable: 0, WaitAvailable: 0}
+	open := CourseSection{OpenSection: true, SeatsAvailable: 3, WaitAvailable: 0}
+	waitlist := CourseSection{SeatsAvailable: 0, WaitAvailable: 2}
+
+	tests := []struct {
+		prev, cur CourseSection
+		want      []string
+	}{
+		{full, open, []string{watchOpened}},
+		{open, full, []string{watchFilled}},
+		{full, waitlist, []string{watchWaitlistOpened}},
+		{waitlist, open, []string{watchOpened, watchWaitlistFilled}},
+		{open, open, nil},
+	}
+	for _, tt := range tests {
+		if got := seatTransitions(tt.prev, tt.cur); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("seatTransitions(%+v, %+v) = %v, want %v", tt.prev, tt.cur, got, tt.want)
+		}
+	}
+}
+
+// smtpStandIn accepts mail like a relay that needs no authentication and
+// keeps every message it is given.
+type smtpStandIn struct {
+	net.Listener
+	mu       sync.Mutex
+	messages []string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStandIn{Listener: l}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+		case "EHLO", "HELO":
+			fmt.Fprint(conn, "250 localhost\r\n")
+		case "DATA":
+			fmt.Fprint(conn, "354 go ahead\r\n")
+			var msg strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				msg.WriteString(line)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg.String())
+			s.mu.Unlock()
+			fmt.Fprint(conn, "250 queued\r\n")
+		case "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "250 ok\r\n")
+		}
+	}
+}
+
+func TestWatchNotifies(t *testing.T) {
+	var mu sync.Mutex
+	var posted []WatchEvent
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var e WatchEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("webhook body %s: %v", body, err)
+		}
+		mu.Lock()
+		posted = append(posted, e)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+	relay := newSMTPStandIn(t)
+	mailer, err := newSMTPNotifier(relay.Addr().String(), "watch@example.com", "student@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// CRN 20001 fills, then a seat opens up again
+	states := []CourseSection{
+		{SeatsAvailable: 1, OpenSection: true},
+		{SeatsAvailable: 0},
+		{SeatsAvailable: 2, OpenSection: true},
+	}
+	polls := 0
+	fetch := func(ctx context.Context, term, crn string) (*CourseSection, error) {
+		s := states[polls]
+		s.Subject, s.CourseNumber, s.Section, s.CourseReferenceNumber, s.CourseTitle = "CSC", "110", "A01", crn, "Fundamentals of Programming I"
+		return &s, nil
+	}
+
+	var stream strings.Builder
+	w := &watcher{
+		term:  "202501",
+		fetch: fetch,
+		notifiers: []Notifier{
+			streamNotifier{w: &stream, jsonMode: true},
+			webhookNotifier{url: webhook.URL, client: webhook.Client()},
+			mailer,
+		},
+		last: make(map[string]CourseSection),
+	}
+	for polls = 0; polls < len(states); polls++ {
+		w.poll(context.Background(), []string{"20001"})
+	}
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(stream.String()), "\n") {
+		var e WatchEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("event %q: %v", line, err)
+		}
+		events = append(events, e.Event)
+	}
+	if want := []string{watchFilled, watchOpened}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	mu.Lock()
+	if len(posted) != 2 || posted[1].Event != watchOpened || posted[1].SeatsAvailable != 2 || posted[1].CRN != "20001" {
+		t.Errorf("webhook got %+v", posted)
+	}
+	mu.Unlock()
+
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	if len(relay.messages) != 2 || !strings.Contains(relay.messages[1], "Subject: CSC 110 A01 (CRN 20001) opened: 2 seats available") {
+		t.Errorf("emails = %q", relay.messages)
+	}
+}
+
+func TestWatchPollsBanner(t *testing.T) {
+	fake := newFakeUVic(t)
+	start := time.Now()
+	out := runMain(t, fake, scratchDir(t), "-watch", "-polls=1", "20001")
+	if !strings.Contains(out, "Watching CSC 110 A01 (CRN 20001): 20 seats, 50 waitlist spots available") {
+		t.Errorf("output:\n%s", out)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("a single poll waited for the interval")
+	}
+}
+
+func TestSMTPNotifierGivesUpOnASilentServer(t *testing.T) {
+	// Accepts connections but never greets or answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	mailer, err := newSMTPNotifier(l.Addr().String(), "watch@example.com", "student@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mailer.timeout = 100 * time.Millisecond
+	e := WatchEvent{Event: watchOpened, Term: "202501", CRN: "20001"}
+
+	start := time.Now()
+	if err := mailer.Notify(context.Background(), e); err == nil {
+		t.Error("email to a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("email to a silent server took %v", elapsed)
+	}
+
+	// Cancelling the watch stops a send even without a timeout
+	mailer.timeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if err := mailer.Notify(ctx, e); err == nil {
+		t.Error("email to a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled email took %v", elapsed)
+	}
+}